@@ -0,0 +1,62 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/jkaninda/goma-docker-provider/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// runConfig implements the config subcommands.
+func runConfig(args []string) int {
+	if len(args) == 0 || args[0] != "print" {
+		_, _ = fmt.Fprintln(os.Stderr, "Usage: goma-provider config print [flags]")
+		return 2
+	}
+
+	fs := flag.NewFlagSet("config print", flag.ContinueOnError)
+	flags := config.RegisterFlags(fs)
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	cfg, err := config.Load(flags)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		return 1
+	}
+
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to marshal configuration: %v\n", err)
+		return 1
+	}
+	_, _ = os.Stdout.Write(data)
+	return 0
+}
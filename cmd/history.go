@@ -0,0 +1,191 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jkaninda/goma-docker-provider/internal"
+	"github.com/jkaninda/goma-docker-provider/internal/config"
+)
+
+const historyUsage = `Usage:
+  goma-provider history [list] [flags]         List configuration snapshots
+  goma-provider history show ID [flags]        Print a snapshot
+  goma-provider history diff ID [ID] [flags]   Diff a snapshot against another one or the current file
+  goma-provider rollback ID [flags]            Restore a snapshot and pin it
+  goma-provider unpin [flags]                  Resume generating the configuration`
+
+// loadProvider parses the configuration flags of a subcommand and returns the
+// positional arguments along with the provider.
+func loadProvider(name string, args []string) (*internal.Provider, []string, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags := config.RegisterFlags(fs)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintln(fs.Output(), historyUsage)
+		fs.PrintDefaults()
+	}
+
+	// Allow flags after positional arguments
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+
+	cfg, err := config.Load(flags)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return internal.NewProvider(cfg), positional, nil
+}
+
+func runHistory(args []string) int {
+	action := "list"
+	if len(args) > 0 && (args[0] == "list" || args[0] == "show" || args[0] == "diff") {
+		action, args = args[0], args[1:]
+	}
+
+	provider, args, err := loadProvider("history", args)
+	if err != nil {
+		return usageError(err)
+	}
+	history := provider.History()
+	if history == nil {
+		_, _ = fmt.Fprintln(os.Stderr, "history is disabled (historyLimit is 0)")
+		return 1
+	}
+
+	switch action {
+	case "list":
+		snapshots, err := history.List()
+		if err != nil {
+			return fail(err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "ID\tCREATED\tROUTES\tPINNED")
+		for _, snapshot := range snapshots {
+			pinned := ""
+			if snapshot.Pinned {
+				pinned = "yes"
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", snapshot.ID, snapshot.Created.Local().Format(time.RFC3339), snapshot.Routes, pinned)
+		}
+		_ = w.Flush()
+
+	case "show":
+		if len(args) != 1 {
+			return usageError(errors.New("show requires a snapshot id"))
+		}
+		data, err := history.Read(args[0])
+		if err != nil {
+			return fail(err)
+		}
+		_, _ = os.Stdout.Write(data)
+
+	case "diff":
+		if len(args) < 1 || len(args) > 2 {
+			return usageError(errors.New("diff requires one or two snapshot ids"))
+		}
+		from, err := history.Read(args[0])
+		if err != nil {
+			return fail(err)
+		}
+		toName := provider.OutputFile()
+		var to []byte
+		if len(args) == 2 {
+			toName = args[1]
+			to, err = history.Read(args[1])
+		} else {
+			to, err = provider.CurrentConfiguration()
+			if errors.Is(err, fs.ErrNotExist) {
+				err = nil
+			}
+		}
+		if err != nil {
+			return fail(err)
+		}
+		fmt.Print(internal.UnifiedDiff(args[0], toName, from, to))
+	}
+	return 0
+}
+
+func runRollback(args []string) int {
+	provider, args, err := loadProvider("rollback", args)
+	if err != nil {
+		return usageError(err)
+	}
+	if len(args) != 1 {
+		return usageError(errors.New("rollback requires a snapshot id"))
+	}
+	if err := provider.Rollback(args[0]); err != nil {
+		return fail(err)
+	}
+	fmt.Printf("Restored snapshot %s and pinned it, run 'goma-provider unpin' to resume generating routes\n", args[0])
+	return 0
+}
+
+func runUnpin(args []string) int {
+	provider, _, err := loadProvider("unpin", args)
+	if err != nil {
+		return usageError(err)
+	}
+	history := provider.History()
+	if history == nil {
+		_, _ = fmt.Fprintln(os.Stderr, "history is disabled (historyLimit is 0)")
+		return 1
+	}
+	if err := history.Unpin(); err != nil {
+		return fail(err)
+	}
+	fmt.Println("Unpinned, routes will be generated on the next sync")
+	return 0
+}
+
+func usageError(err error) int {
+	if !errors.Is(err, flag.ErrHelp) {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		_, _ = fmt.Fprintln(os.Stderr, historyUsage)
+	}
+	return 2
+}
+
+func fail(err error) int {
+	_, _ = fmt.Fprintln(os.Stderr, err)
+	return 1
+}
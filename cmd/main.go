@@ -27,21 +27,57 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
 
 	"github.com/jkaninda/goma-docker-provider/internal"
+	"github.com/jkaninda/goma-docker-provider/internal/config"
 	"github.com/jkaninda/logger"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "validate":
+			os.Exit(runValidate(os.Args[2:]))
+		case "render":
+			os.Exit(runRender(os.Args[2:]))
+		case "config":
+			os.Exit(runConfig(os.Args[2:]))
+		case "history":
+			os.Exit(runHistory(os.Args[2:]))
+		case "rollback":
+			os.Exit(runRollback(os.Args[2:]))
+		case "unpin":
+			os.Exit(runUnpin(os.Args[2:]))
+		case "schema":
+			os.Exit(runSchema(os.Args[2:]))
+		}
+	}
+	run(os.Args[1:])
+}
+
+func run(args []string) {
+	fs := flag.NewFlagSet("goma-provider", flag.ExitOnError)
+	flags := config.RegisterFlags(fs)
+	_ = fs.Parse(args)
+
+	cfg, err := config.Load(flags)
+	if err != nil {
+		logger.Error("Invalid configuration", "error", err)
+		os.Exit(1)
+	}
+	setLogLevel(cfg.LogLevel)
+
 	logger.Info("Starting Goma Docker provider...")
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	provider := internal.NewProvider()
+	provider := internal.NewProvider(cfg)
 
 	errCh := make(chan error, 1)
 	sigCh := make(chan os.Signal, 1)
@@ -68,3 +104,14 @@ func main() {
 		}
 	}
 }
+
+// setLogLevel sets the minimum level of the default logger.
+func setLogLevel(level string) {
+	levels := map[string]slog.Level{
+		"debug":   slog.LevelDebug,
+		"info":    slog.LevelInfo,
+		"warning": slog.LevelWarn,
+		"error":   slog.LevelError,
+	}
+	slog.SetLogLoggerLevel(levels[level])
+}
@@ -0,0 +1,88 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/jkaninda/goma-docker-provider/internal"
+	"github.com/jkaninda/goma-docker-provider/internal/config"
+)
+
+// runRender runs a single sync pass against the Docker daemon and prints the
+// generated configuration instead of writing it.
+func runRender(args []string) int {
+	flags := flag.NewFlagSet("render", flag.ContinueOnError)
+	format := flags.String("format", internal.FormatYAML, "Output format: yaml or json")
+	diff := flags.Bool("diff", false, "Print a unified diff against the current generated file")
+	configFlags := config.RegisterFlags(flags)
+	flags.Usage = func() {
+		_, _ = fmt.Fprintln(flags.Output(), "Usage: goma-provider render [flags]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *format != internal.FormatYAML && *format != internal.FormatJSON {
+		_, _ = fmt.Fprintf(os.Stderr, "unsupported format %q\n", *format)
+		return 2
+	}
+	if *diff {
+		// The output file is always YAML
+		*format = internal.FormatYAML
+	}
+
+	cfg, err := config.Load(configFlags)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		return 1
+	}
+	setLogLevel(cfg.LogLevel)
+
+	provider := internal.NewProvider(cfg)
+	data, err := provider.Render(context.Background(), *format)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "render failed: %v\n", err)
+		return 1
+	}
+
+	if !*diff {
+		_, _ = os.Stdout.Write(data)
+		return 0
+	}
+
+	current, err := provider.CurrentConfiguration()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to read current configuration: %v\n", err)
+		return 1
+	}
+	fmt.Print(internal.UnifiedDiff(provider.OutputFile(), "rendered", current, data))
+	return 0
+}
@@ -0,0 +1,123 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/jkaninda/goma-docker-provider/internal"
+	"github.com/jkaninda/goma-docker-provider/internal/config"
+)
+
+// runValidate parses the goma labels of compose or stack files offline and
+// prints the routes they would produce. It returns a non-zero exit code when
+// any file has errors.
+func runValidate(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	swarmMode := fs.Bool("swarm", false, "Read a Swarm stack file (deploy labels) instead of a compose file")
+	project := fs.String("project", "", "Compose project or stack name (default: compose name or directory name)")
+	format := fs.String("format", "yaml", "Output format: yaml or json")
+	quiet := fs.Bool("quiet", false, "Only print diagnostics")
+	strict := fs.Bool("strict", false, "Treat warnings as errors")
+	policy := fs.String("policy", "", "Apply a policy file to the routes")
+	conflictPolicy := fs.String("conflict-policy", "", "Route conflict policy: warn, reject or require-priority (default: warn)")
+	groupByProject := fs.Bool("group-by-project", false, "Prefix route names with their compose project or stack")
+	entryPoints := fs.String("entry-points", "", "Comma-separated gateway entry points routes may listen on")
+	gatewayConfig := fs.String("gateway-config", "", "Read the known entry points from the gateway goma.yml")
+	targetVersion := fs.String("target-version", "", "Gateway configuration version of the output: 1 or 2 (default: 2)")
+	traefikCompat := fs.Bool("traefik-compat", false, "Translate the Traefik labels of services without goma labels")
+	fs.Usage = func() {
+		_, _ = fmt.Fprintln(fs.Output(), "Usage: goma-provider validate [flags] FILE...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	if *format != "yaml" && *format != "json" {
+		_, _ = fmt.Fprintf(os.Stderr, "unsupported format %q\n", *format)
+		return 2
+	}
+
+	failed := false
+	for _, file := range fs.Args() {
+		result, err := internal.ValidateComposeFile(file, internal.ValidateOptions{
+			Swarm:          *swarmMode,
+			Project:        *project,
+			PolicyFile:     *policy,
+			ConflictPolicy: *conflictPolicy,
+			GroupByProject: *groupByProject,
+			EntryPoints:    config.SplitList(*entryPoints),
+			GatewayConfig:  *gatewayConfig,
+			TargetVersion:  *targetVersion,
+			TraefikCompat:  *traefikCompat,
+		})
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "%s: %v\n", file, err)
+			failed = true
+			continue
+		}
+		if result.HasErrors() || (*strict && result.HasWarnings()) {
+			failed = true
+		}
+		if err := printValidation(file, result, *format, *targetVersion, *quiet); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "%s: %v\n", file, err)
+			return 2
+		}
+	}
+
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+func printValidation(file string, result *internal.ValidationResult, format, version string, quiet bool) error {
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+
+	if !quiet {
+		config := internal.GomaConfig{Routes: result.Routes, L4Routes: result.L4Routes, Middlewares: result.Middlewares}
+		data, err := internal.MarshalConfiguration(config, internal.FormatYAML, version)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("# %s\n%s", file, data)
+	}
+	for _, d := range result.Diagnostics {
+		_, _ = fmt.Fprintf(os.Stderr, "%s: %s\n", file, d)
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "%s: %d route(s), %d diagnostic(s)\n", file, len(result.Routes)+len(result.L4Routes), len(result.Diagnostics))
+	return nil
+}
@@ -4,7 +4,6 @@ go 1.25.5
 
 require (
 	github.com/docker/docker v28.5.2+incompatible
-	github.com/jkaninda/go-utils v0.1.4
 	github.com/jkaninda/logger v0.0.5
 	github.com/joho/godotenv v1.5.1
 	gopkg.in/yaml.v3 v3.0.1
@@ -0,0 +1,87 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"net/netip"
+)
+
+// Access policy actions
+const (
+	AccessAllow = "ALLOW"
+	AccessDeny  = "DENY"
+)
+
+// accessMiddlewares builds the private access policy middlewares of the
+// goma.access.allow and goma.access.deny fields of a route, deny first. It
+// reports false when a range is invalid, the route is rejected then.
+func (p *Provider) accessMiddlewares(fc fieldContext, name string, fields map[string]string) ([]Middleware, bool) {
+	middlewares := make([]Middleware, 0, 2)
+	for _, access := range []struct{ field, action, suffix string }{
+		{"access.deny", AccessDeny, "access-deny"},
+		{"access.allow", AccessAllow, "access-allow"},
+	} {
+		value, set := fields[access.field]
+		if !set {
+			continue
+		}
+		ranges, ok := p.parseSourceRanges(fc, name, access.field, value)
+		if !ok {
+			return nil, false
+		}
+		middlewares = append(middlewares, privateMiddleware(name, access.suffix, MiddlewareAccessPolicy, AccessPolicyRule{
+			Action:       access.action,
+			SourceRanges: ranges,
+		}))
+	}
+	return middlewares, true
+}
+
+// parseSourceRanges parses a list of CIDRs or IP addresses into canonical CIDRs.
+func (p *Provider) parseSourceRanges(fc fieldContext, name, field, value string) ([]string, bool) {
+	items := parseList(value)
+	if len(items) == 0 {
+		p.errorf(fc.source, fc.key(field), "route %q rejected: empty source range list", name)
+		return nil, false
+	}
+	ranges := make([]string, 0, len(items))
+	for _, item := range items {
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(item)
+			if addrErr != nil {
+				p.errorf(fc.source, fc.key(field), "route %q rejected: invalid CIDR or IP address %q", name, item)
+				return nil, false
+			}
+			prefix = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+		}
+		if masked := prefix.Masked(); masked != prefix {
+			p.warnf(fc.source, fc.key(field), "%s has host bits set, %s is used", item, masked)
+			prefix = masked
+		}
+		ranges = append(ranges, prefix.String())
+	}
+	return ranges, true
+}
@@ -0,0 +1,247 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/swarm"
+)
+
+// addressIndex maps container and service names, and compose services by
+// project, to the host they are reachable at.
+type addressIndex map[string]string
+
+// containerAddresses indexes containers by name and by compose service.
+func containerAddresses(containers []container.Summary) addressIndex {
+	index := make(addressIndex, 2*len(containers))
+	for _, c := range containers {
+		name := containerName(c)
+		index[name] = name
+		if service := c.Labels["com.docker.compose.service"]; service != "" {
+			index[c.Labels["com.docker.compose.project"]+"/"+service] = name
+		}
+	}
+	return index
+}
+
+// serviceAddresses indexes Swarm services by name and by name in their stack.
+func serviceAddresses(services []swarm.Service) addressIndex {
+	index := make(addressIndex, 2*len(services))
+	for _, service := range services {
+		name := service.Spec.Name
+		index[name] = name
+		if stack := service.Spec.Labels["com.docker.stack.namespace"]; stack != "" {
+			index[stack+"/"+strings.TrimPrefix(name, stack+"_")] = name
+		}
+	}
+	return index
+}
+
+// lookup returns the host of a container or service, a compose service or
+// stack service of the project taking precedence.
+func (a addressIndex) lookup(name, project string) (string, bool) {
+	if host, ok := a[project+"/"+name]; ok && project != "" {
+		return host, true
+	}
+	host, ok := a[name]
+	return host, ok
+}
+
+// authMiddlewares builds the private middlewares of the goma.auth.* fields
+// of a route. It reports false when the fields are invalid, the route is
+// rejected then rather than published unprotected. Settings of a method that
+// is not enabled are only reported when set by a label of the route.
+func (p *Provider) authMiddlewares(fc fieldContext, name, project string, fields, sources map[string]string) ([]Middleware, bool) {
+	middlewares := make([]Middleware, 0)
+	ok := true
+	if users, set := fields["auth.basic.users"]; set {
+		if rule, valid := p.basicAuthRule(fc, name, users, fields["auth.basic.realm"]); valid {
+			middlewares = append(middlewares, privateMiddleware(name, "basic-auth", MiddlewareBasicAuth, rule))
+		} else {
+			ok = false
+		}
+	}
+	_, container := fields["auth.forward.container"]
+	_, authURL := fields["auth.forward.url"]
+	if container || authURL {
+		if rule, valid := p.forwardAuthRule(fc, name, project, fields); valid {
+			middlewares = append(middlewares, privateMiddleware(name, "forward-auth", MiddlewareForwardAuth, rule))
+		} else {
+			ok = false
+		}
+	}
+	if jwksURL, set := fields["auth.jwt.jwks_url"]; set {
+		if validHTTPURL(jwksURL) {
+			middlewares = append(middlewares, privateMiddleware(name, "jwt-auth", MiddlewareJWTAuth, JWTAuthRule{
+				JwksURL:  jwksURL,
+				Issuer:   fields["auth.jwt.issuer"],
+				Audience: fields["auth.jwt.audience"],
+			}))
+		} else {
+			p.errorf(fc.source, fc.key("auth.jwt.jwks_url"), "route %q rejected: invalid URL %q", name, jwksURL)
+			ok = false
+		}
+	}
+	for _, field := range []string{"auth.basic.realm", "auth.forward.port", "auth.forward.path", "auth.forward.response_headers", "auth.jwt.issuer", "auth.jwt.audience"} {
+		if sources[field] == sourceLabel && !authConfigured(fields, field) {
+			p.warnf(fc.source, fc.key(field), "ignored on route %q, its authentication is not enabled", name)
+		}
+	}
+	return middlewares, ok
+}
+
+// authConfigured reports whether the authentication method of a field is enabled.
+func authConfigured(fields map[string]string, field string) bool {
+	switch {
+	case strings.HasPrefix(field, "auth.basic."):
+		_, ok := fields["auth.basic.users"]
+		return ok
+	case strings.HasPrefix(field, "auth.forward."):
+		_, container := fields["auth.forward.container"]
+		_, authURL := fields["auth.forward.url"]
+		return container || authURL
+	default:
+		_, ok := fields["auth.jwt.jwks_url"]
+		return ok
+	}
+}
+
+// generatedMiddlewarePrefix namespaces the generated middleware names, so
+// they do not collide with the middlewares of the gateway configuration,
+// such as a basic-auth middleware.
+const generatedMiddlewarePrefix = "docker-"
+
+// privateMiddleware returns a middleware named after its route, applied to every path.
+func privateMiddleware(route, suffix, kind string, rule any) Middleware {
+	return Middleware{
+		Name:  fmt.Sprintf("%s%s-%s", generatedMiddlewarePrefix, route, suffix),
+		Type:  kind,
+		Paths: []string{"/.*"},
+		Rule:  rule,
+	}
+}
+
+// basicAuthRule parses user:hash pairs separated by commas or new lines,
+// as read from a secret file. Values are never included in diagnostics.
+func (p *Provider) basicAuthRule(fc fieldContext, name, users, realm string) (BasicAuthRule, bool) {
+	rule := BasicAuthRule{Realm: realm}
+	entries := strings.FieldsFunc(users, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r'
+	})
+	for i, entry := range entries {
+		username, password, found := strings.Cut(strings.TrimSpace(entry), ":")
+		if !found || username == "" || password == "" {
+			p.errorf(fc.source, fc.key("auth.basic.users"), "route %q rejected: user %d is not in the user:hash form", name, i+1)
+			return BasicAuthRule{}, false
+		}
+		if !isBcryptHash(password) {
+			p.warnf(fc.source, fc.key("auth.basic.users"), "password of user %q on route %q is not a bcrypt hash", username, name)
+		}
+		rule.Users = append(rule.Users, BasicAuthUser{Username: username, Password: password})
+	}
+	if len(rule.Users) == 0 {
+		p.errorf(fc.source, fc.key("auth.basic.users"), "route %q rejected: no users", name)
+		return BasicAuthRule{}, false
+	}
+	return rule, true
+}
+
+func isBcryptHash(password string) bool {
+	return len(password) == 60 && (strings.HasPrefix(password, "$2a$") || strings.HasPrefix(password, "$2b$") || strings.HasPrefix(password, "$2y$"))
+}
+
+// forwardAuthRule builds the rule of a forward auth middleware, resolving
+// the address of the authentication container or service.
+func (p *Provider) forwardAuthRule(fc fieldContext, name, project string, fields map[string]string) (ForwardAuthRule, bool) {
+	rule := ForwardAuthRule{AuthResponseHeaders: parseList(fields["auth.forward.response_headers"])}
+	containerName, container := fields["auth.forward.container"]
+	authURL, hasURL := fields["auth.forward.url"]
+	switch {
+	case container && hasURL:
+		p.errorf(fc.source, fc.key("auth.forward.url"), "route %q rejected: set either %s or %s", name, fc.key("auth.forward.container"), fc.key("auth.forward.url"))
+		return ForwardAuthRule{}, false
+	case hasURL:
+		if !validHTTPURL(authURL) {
+			p.errorf(fc.source, fc.key("auth.forward.url"), "route %q rejected: invalid URL %q", name, authURL)
+			return ForwardAuthRule{}, false
+		}
+		rule.AuthURL = authURL
+		return rule, true
+	}
+
+	host, found := p.addresses.lookup(containerName, project)
+	if !found {
+		p.errorf(fc.source, fc.key("auth.forward.container"), "route %q rejected: container %q not found", name, containerName)
+		return ForwardAuthRule{}, false
+	}
+	port := getRouteLabel(fields, "auth.forward.port", "80")
+	if !validPort(port) {
+		p.errorf(fc.source, fc.key("auth.forward.port"), "route %q rejected: invalid port %q", name, port)
+		return ForwardAuthRule{}, false
+	}
+	path := getRouteLabel(fields, "auth.forward.path", "/")
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	rule.AuthURL = "http://" + net.JoinHostPort(host, port) + path
+	return rule, true
+}
+
+func validHTTPURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// collectMiddlewares moves the generated middlewares of the routes to the
+// middlewares section. A route whose middleware name is already taken is
+// rejected, it would otherwise be protected by another route's middleware.
+func (p *Provider) collectMiddlewares(routes []Route) ([]Route, []Middleware) {
+	var middlewares []Middleware
+	owners := make(map[string]string)
+	kept := make([]Route, 0, len(routes))
+	for _, route := range routes {
+		rejected := false
+		for _, middleware := range route.GeneratedMiddlewares {
+			if owner, taken := owners[middleware.Name]; taken {
+				p.errorf(route.Source, "", "route %q rejected: generated middleware %q is already used by %s", route.Name, middleware.Name, owner)
+				rejected = true
+			}
+		}
+		if rejected {
+			continue
+		}
+		for _, middleware := range route.GeneratedMiddlewares {
+			owners[middleware.Name] = route.Source
+			middlewares = append(middlewares, middleware)
+		}
+		kept = append(kept, route)
+	}
+	return kept, middlewares
+}
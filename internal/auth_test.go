@@ -0,0 +1,294 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jkaninda/goma-docker-provider/internal/config"
+)
+
+const testBcryptHash = "$2y$05$TIx7l8sJWvMFXw4n0GbkQuOhemPQOormacQC4W1p28TOVzJtx.XpO"
+
+func TestAuthMiddlewareNames(t *testing.T) {
+	p := NewProvider(config.Default())
+	parsed := parseTestContainer(p, "api", map[string]string{
+		"goma.enable":            "true",
+		"goma.name":              "basic-auth",
+		"goma.middlewares":       "rate-limit",
+		"goma.auth.basic.users":  "admin:" + testBcryptHash,
+		"goma.auth.jwt.jwks_url": "https://auth.example.com/.well-known/jwks.json",
+	})
+	if len(parsed.Routes) != 1 {
+		t.Fatalf("got %d routes: %v", len(parsed.Routes), p.diagnostics)
+	}
+	route := parsed.Routes[0]
+	want := []string{"docker-basic-auth-basic-auth", "docker-basic-auth-jwt-auth", "rate-limit"}
+	if !equalStrings(route.Middlewares, want) {
+		t.Errorf("middlewares = %v, want %v", route.Middlewares, want)
+	}
+	for _, middleware := range route.GeneratedMiddlewares {
+		if !strings.HasPrefix(middleware.Name, generatedMiddlewarePrefix) {
+			t.Errorf("generated middleware %q is not namespaced", middleware.Name)
+		}
+		// A route named basic-auth does not take the name of a gateway middleware
+		if middleware.Name == "basic-auth" {
+			t.Errorf("generated middleware collides with the gateway basic-auth middleware")
+		}
+	}
+}
+
+func TestBasicAuthRule(t *testing.T) {
+	tests := []struct {
+		name     string
+		users    string
+		want     int
+		ok       bool
+		warnings bool
+	}{
+		{"one user", "admin:" + testBcryptHash, 1, true, false},
+		{"users from a secret file", "admin:" + testBcryptHash + "\nops:" + testBcryptHash + "\n", 2, true, false},
+		{"plain password", "admin:secret", 1, true, true},
+		{"missing hash", "admin", 0, false, false},
+		{"empty user", ":" + testBcryptHash, 0, false, false},
+		{"no users", " , ", 0, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewProvider(config.Default())
+			rule, ok := p.basicAuthRule(fieldContext{source: "api", prefix: "goma."}, "api", tt.users, "")
+			if ok != tt.ok || len(rule.Users) != tt.want {
+				t.Fatalf("basicAuthRule() = %d users, %v, want %d, %v (diagnostics: %v)", len(rule.Users), ok, tt.want, tt.ok, p.diagnostics)
+			}
+			if hasWarnings(p.diagnostics) != (tt.warnings || !tt.ok) {
+				t.Errorf("diagnostics = %v", p.diagnostics)
+			}
+			for _, d := range p.diagnostics {
+				if strings.Contains(d.Message, "secret") || strings.Contains(d.Message, testBcryptHash) {
+					t.Errorf("diagnostic leaks a password: %s", d.Message)
+				}
+			}
+		})
+	}
+}
+
+func TestCollectMiddlewaresDuplicate(t *testing.T) {
+	p := NewProvider(config.Default())
+	middleware := privateMiddleware("api", "basic-auth", MiddlewareBasicAuth, BasicAuthRule{})
+	routes, middlewares := p.collectMiddlewares([]Route{
+		{Name: "api", Source: "api-1", GeneratedMiddlewares: []Middleware{middleware}},
+		{Name: "api", Source: "api-2", GeneratedMiddlewares: []Middleware{middleware}},
+		{Name: "web", Source: "web-1"},
+	})
+	if len(routes) != 2 || routes[0].Source != "api-1" || routes[1].Name != "web" {
+		t.Errorf("routes = %v, want the second api route rejected", routes)
+	}
+	if len(middlewares) != 1 || middlewares[0].Name != "docker-api-basic-auth" {
+		t.Errorf("middlewares = %v", middlewares)
+	}
+	if !hasErrors(p.diagnostics) {
+		t.Error("expected an error for the duplicate middleware")
+	}
+}
+
+func TestParseSourceRanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []string
+		ok      bool
+		warning bool
+	}{
+		{"cidrs", "10.0.0.0/8, 192.168.1.0/24", []string{"10.0.0.0/8", "192.168.1.0/24"}, true, false},
+		{"ip addresses", "10.1.2.3,::1", []string{"10.1.2.3/32", "::1/128"}, true, false},
+		{"ipv4-mapped address", "::ffff:10.1.2.3", []string{"10.1.2.3/32"}, true, false},
+		{"host bits set", "10.1.2.3/8", []string{"10.0.0.0/8"}, true, true},
+		{"invalid range", "10.0.0.0/8,internal", nil, false, false},
+		{"empty list", " , ", nil, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewProvider(config.Default())
+			got, ok := p.parseSourceRanges(fieldContext{source: "web", prefix: "goma."}, "web", "access.allow", tt.value)
+			if ok != tt.ok || !equalStrings(got, tt.want) {
+				t.Fatalf("parseSourceRanges() = %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+			if hasErrors(p.diagnostics) == ok {
+				t.Errorf("diagnostics = %v", p.diagnostics)
+			}
+			if tt.warning != (ok && hasWarnings(p.diagnostics)) {
+				t.Errorf("diagnostics = %v, want a warning: %v", p.diagnostics, tt.warning)
+			}
+		})
+	}
+}
+
+func TestAccessMiddlewares(t *testing.T) {
+	p := NewProvider(config.Default())
+	parsed := parseTestContainer(p, "web", map[string]string{
+		"goma.enable":       "true",
+		"goma.middlewares":  "rate-limit",
+		"goma.access.allow": "10.0.0.0/8",
+		"goma.access.deny":  "10.0.0.1",
+	})
+	if len(parsed.Routes) != 1 || len(p.diagnostics) != 0 {
+		t.Fatalf("routes = %v, diagnostics = %v", parsed.Routes, p.diagnostics)
+	}
+	route := parsed.Routes[0]
+	// Denied ranges are checked first, generated middlewares run before the others
+	want := []string{"docker-web-access-deny", "docker-web-access-allow", "rate-limit"}
+	if !equalStrings(route.Middlewares, want) {
+		t.Errorf("middlewares = %v, want %v", route.Middlewares, want)
+	}
+
+	routes, middlewares := p.collectMiddlewares(parsed.Routes)
+	data, err := MarshalConfiguration(GomaConfig{Routes: routes, Middlewares: middlewares}, FormatYAML, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"type: accessPolicy", "action: DENY", "- 10.0.0.1/32", "action: ALLOW", "- 10.0.0.0/8"} {
+		if !strings.Contains(string(data), line) {
+			t.Errorf("output does not contain %q:\n%s", line, data)
+		}
+	}
+}
+
+func TestAccessInvalidRangeRejectsRoute(t *testing.T) {
+	p := NewProvider(config.Default())
+	parsed := parseTestContainer(p, "web", map[string]string{
+		"goma.enable":                   "true",
+		"goma.access.allow":             "10.0.0.0/33",
+		"goma.routes.api.path":          "/api",
+		"goma.routes.open.path":         "/open",
+		"goma.routes.open.access.allow": "0.0.0.0/0,::/0",
+	})
+	// The named route without its own list inherits the invalid container list
+	if len(parsed.Routes) != 1 || parsed.Routes[0].Name != "web-open" {
+		t.Errorf("routes = %v, want only the route with its own list", parsed.Routes)
+	}
+	if !hasErrors(p.diagnostics) {
+		t.Error("expected an invalid range error")
+	}
+}
+
+func TestHeaderMiddleware(t *testing.T) {
+	p := NewProvider(config.Default())
+	fields := map[string]string{
+		"headers.request.set.x-forwarded-proto": "https",
+		"headers.request.remove":                "x-powered-by, cookie",
+		"headers.response.set.X-Frame-Options":  "DENY",
+	}
+	middlewares, ok := p.headerMiddleware(fieldContext{source: "web", prefix: "goma."}, "web", fields)
+	if !ok || len(middlewares) != 1 || len(p.diagnostics) != 0 {
+		t.Fatalf("headerMiddleware() = %v, %v, diagnostics = %v", middlewares, ok, p.diagnostics)
+	}
+	middleware := middlewares[0]
+	if middleware.Name != "docker-web-headers" || middleware.Type != MiddlewareHeaders {
+		t.Errorf("middleware = %s %s, want docker-web-headers %s", middleware.Name, middleware.Type, MiddlewareHeaders)
+	}
+	rule := middleware.Rule.(HeadersRule)
+	if rule.Request.Set["X-Forwarded-Proto"] != "https" || len(rule.Request.Set) != 1 {
+		t.Errorf("request set = %v", rule.Request.Set)
+	}
+	if !equalStrings(rule.Request.Remove, []string{"X-Powered-By", "Cookie"}) {
+		t.Errorf("request remove = %v", rule.Request.Remove)
+	}
+	if rule.Response.Set["X-Frame-Options"] != "DENY" || rule.Response.Remove != nil {
+		t.Errorf("response = %+v", rule.Response)
+	}
+
+	// No headers fields, no middleware
+	middlewares, ok = p.headerMiddleware(fieldContext{source: "web", prefix: "goma."}, "web", map[string]string{"path": "/"})
+	if !ok || middlewares != nil {
+		t.Errorf("headerMiddleware() = %v, %v, want no middleware", middlewares, ok)
+	}
+}
+
+func TestHeaderMiddlewareDiagnostics(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  map[string]string
+		ok      bool
+		message string
+	}{
+		{"invalid set name", map[string]string{"headers.request.set.X Bad": "1"}, false, `invalid header name "X Bad"`},
+		{"invalid remove name", map[string]string{"headers.response.remove": "Server,X:Bad"}, false, `invalid header name "X:Bad"`},
+		{"control character", map[string]string{"headers.response.set.X-Test": "a\r\nSet-Cookie: b"}, false, "control character"},
+		{"set more than once", map[string]string{
+			"headers.request.set.x-test": "a",
+			"headers.request.set.X-Test": "b",
+		}, true, "set more than once"},
+		{"set and removed", map[string]string{
+			"headers.request.set.X-Test": "a",
+			"headers.request.remove":     "x-test",
+		}, true, "both set and removed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewProvider(config.Default())
+			middlewares, ok := p.headerMiddleware(fieldContext{source: "web", prefix: "goma."}, "web", tt.fields)
+			if ok != tt.ok || (ok && len(middlewares) != 1) || (!ok && middlewares != nil) {
+				t.Fatalf("headerMiddleware() = %v, %v, want ok %v", middlewares, ok, tt.ok)
+			}
+			if len(p.diagnostics) != 1 || !strings.Contains(p.diagnostics[0].Message, tt.message) {
+				t.Fatalf("diagnostics = %v, want %q", p.diagnostics, tt.message)
+			}
+			if hasErrors(p.diagnostics) == ok {
+				t.Errorf("severity = %s, want an error: %v", p.diagnostics[0].Severity, !ok)
+			}
+		})
+	}
+}
+
+func TestHeadersOutput(t *testing.T) {
+	p := NewProvider(config.Default())
+	parsed := parseTestContainer(p, "web", map[string]string{
+		"goma.enable":           "true",
+		"goma.auth.basic.users": "admin:" + testBcryptHash,
+		"goma.headers.response.set.x-frame-options": "DENY",
+		"goma.routes.api.path":                      "/api",
+		"goma.routes.api.headers.request.remove":    "cookie",
+	})
+	if len(parsed.Routes) != 1 || hasErrors(p.diagnostics) {
+		t.Fatalf("routes = %v, diagnostics = %v", parsed.Routes, p.diagnostics)
+	}
+	// Headers are applied after authentication
+	want := []string{"docker-web-api-basic-auth", "docker-web-api-headers"}
+	if !equalStrings(parsed.Routes[0].Middlewares, want) {
+		t.Errorf("middlewares = %v, want %v", parsed.Routes[0].Middlewares, want)
+	}
+
+	routes, middlewares := p.collectMiddlewares(parsed.Routes)
+	data, err := MarshalConfiguration(GomaConfig{Routes: routes, Middlewares: middlewares}, FormatYAML, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"type: headers", "X-Frame-Options: DENY", "- Cookie"} {
+		if !strings.Contains(string(data), line) {
+			t.Errorf("output does not contain %q:\n%s", line, data)
+		}
+	}
+}
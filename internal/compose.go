@@ -0,0 +1,213 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/swarm"
+	"gopkg.in/yaml.v3"
+)
+
+// composeFile is the subset of a docker-compose or Swarm stack file the provider reads.
+type composeFile struct {
+	Name     string                    `yaml:"name"`
+	Services map[string]composeService `yaml:"services"`
+}
+
+type composeService struct {
+	ContainerName string        `yaml:"container_name"`
+	Image         string        `yaml:"image"`
+	Labels        composeLabels `yaml:"labels"`
+	Ports         []composePort `yaml:"ports"`
+	Deploy        struct {
+		Labels composeLabels `yaml:"labels"`
+	} `yaml:"deploy"`
+}
+
+// composeLabels accepts both the list ("key=value") and the map form of compose labels.
+type composeLabels map[string]string
+
+func (l *composeLabels) UnmarshalYAML(node *yaml.Node) error {
+	labels := make(composeLabels)
+	switch node.Kind {
+	case yaml.MappingNode:
+		raw := make(map[string]string)
+		if err := node.Decode(&raw); err != nil {
+			return err
+		}
+		for key, value := range raw {
+			labels[key] = value
+		}
+	case yaml.SequenceNode:
+		var raw []string
+		if err := node.Decode(&raw); err != nil {
+			return err
+		}
+		for _, item := range raw {
+			key, value, _ := strings.Cut(item, "=")
+			labels[strings.TrimSpace(key)] = value
+		}
+	default:
+		return fmt.Errorf("line %d: labels must be a list or a map", node.Line)
+	}
+	*l = labels
+	return nil
+}
+
+// composePort accepts the short ("8080:80/tcp") and the long ({target: 80})
+// port syntax. A short syntax range ("8000-8010:8000-8010") has a target per
+// port of the range.
+type composePort struct {
+	Targets []uint32
+}
+
+func (p *composePort) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.MappingNode {
+		var long struct {
+			Target uint32 `yaml:"target"`
+		}
+		if err := node.Decode(&long); err != nil {
+			return err
+		}
+		p.Targets = []uint32{long.Target}
+		return nil
+	}
+	var short string
+	if err := node.Decode(&short); err != nil {
+		return err
+	}
+	short, _, _ = strings.Cut(short, "/")
+	parts := strings.Split(short, ":")
+	first, last, isRange := strings.Cut(parts[len(parts)-1], "-")
+	if !isRange {
+		last = first
+	}
+	start, err := strconv.ParseUint(first, 10, 16)
+	if err != nil {
+		return fmt.Errorf("line %d: invalid port %q", node.Line, short)
+	}
+	end, err := strconv.ParseUint(last, 10, 16)
+	if err != nil || end < start {
+		return fmt.Errorf("line %d: invalid port range %q", node.Line, short)
+	}
+	p.Targets = nil
+	for port := start; port <= end; port++ {
+		p.Targets = append(p.Targets, uint32(port))
+	}
+	return nil
+}
+
+func loadComposeFile(path string) (*composeFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file composeFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if file.Name == "" {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		file.Name = filepath.Base(filepath.Dir(abs))
+	}
+	return &file, nil
+}
+
+// serviceNames returns the compose service names in a stable order.
+func (f *composeFile) serviceNames() []string {
+	names := make([]string, 0, len(f.Services))
+	for name := range f.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// containers converts compose services into the container summaries Docker
+// would report once the project is up, named like Compose names them.
+func (f *composeFile) containers() []container.Summary {
+	containers := make([]container.Summary, 0, len(f.Services))
+	for _, name := range f.serviceNames() {
+		service := f.Services[name]
+		containerName := service.ContainerName
+		if containerName == "" {
+			containerName = fmt.Sprintf("%s-%s-1", f.Name, name)
+		}
+		labels := map[string]string(service.Labels)
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels["com.docker.compose.project"] = f.Name
+		labels["com.docker.compose.service"] = name
+		containers = append(containers, container.Summary{
+			Names:  []string{"/" + containerName},
+			Image:  service.Image,
+			Labels: labels,
+		})
+	}
+	return containers
+}
+
+// services converts stack services into the Swarm services Docker would
+// report once the stack is deployed, using deploy labels.
+func (f *composeFile) services() []swarm.Service {
+	services := make([]swarm.Service, 0, len(f.Services))
+	for _, name := range f.serviceNames() {
+		service := f.Services[name]
+		labels := map[string]string(service.Deploy.Labels)
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels["com.docker.stack.namespace"] = f.Name
+
+		spec := swarm.ServiceSpec{
+			Annotations: swarm.Annotations{
+				Name:   fmt.Sprintf("%s_%s", f.Name, name),
+				Labels: labels,
+			},
+		}
+		spec.TaskTemplate.ContainerSpec = &swarm.ContainerSpec{Image: service.Image}
+		if len(service.Ports) > 0 {
+			spec.EndpointSpec = &swarm.EndpointSpec{}
+			for _, port := range service.Ports {
+				for _, target := range port.Targets {
+					spec.EndpointSpec.Ports = append(spec.EndpointSpec.Ports, swarm.PortConfig{TargetPort: target})
+				}
+			}
+		}
+		services = append(services, swarm.Service{Spec: spec})
+	}
+	return services
+}
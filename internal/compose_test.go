@@ -0,0 +1,88 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeComposeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "compose.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestComposePorts(t *testing.T) {
+	path := writeComposeFile(t, `
+name: shop
+services:
+  api:
+    image: api
+    ports:
+      - "8080:80/tcp"
+      - "127.0.0.1:9090:90"
+      - "8000-8002:8000-8002"
+      - "7000-7001/udp"
+      - target: 443
+        published: 8443
+`)
+	file, err := loadComposeFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	services := file.services()
+	if len(services) != 1 || services[0].Spec.EndpointSpec == nil {
+		t.Fatalf("services = %+v", services)
+	}
+	var targets []uint32
+	for _, port := range services[0].Spec.EndpointSpec.Ports {
+		targets = append(targets, port.TargetPort)
+	}
+	want := []uint32{80, 90, 8000, 8001, 8002, 7000, 7001, 443}
+	if len(targets) != len(want) {
+		t.Fatalf("target ports = %v, want %v", targets, want)
+	}
+	for i := range want {
+		if targets[i] != want[i] {
+			t.Fatalf("target ports = %v, want %v", targets, want)
+		}
+	}
+}
+
+func TestComposeInvalidPorts(t *testing.T) {
+	for _, port := range []string{"8080:http", "8010-8000:8010-8000", "8000-:8000-", "70000"} {
+		path := writeComposeFile(t, "services:\n  api:\n    ports:\n      - \""+port+"\"\n")
+		_, err := loadComposeFile(path)
+		if err == nil || !strings.Contains(err.Error(), "invalid port") {
+			t.Errorf("port %q: error = %v, want an invalid port error", port, err)
+		}
+	}
+}
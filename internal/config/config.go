@@ -25,33 +25,496 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
-	goutils "github.com/jkaninda/go-utils"
-	"github.com/jkaninda/logger"
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
+// Config holds the provider configuration.
+//
+// Values are resolved in the following order, the first one set wins:
+// command line flags, environment variables, config file, defaults.
 type Config struct {
-	OutputDir    string
-	PollInterval time.Duration
-	DockerHost   string
-	EnableSwarm  bool
+	OutputDir    string        `yaml:"outputDir" json:"outputDir"`
+	PollInterval time.Duration `yaml:"pollInterval" json:"pollInterval"`
+	DockerHost   string        `yaml:"dockerHost,omitempty" json:"dockerHost,omitempty"`
+	EnableSwarm  bool          `yaml:"enableSwarm" json:"enableSwarm"`
+	// LeaderElection makes replicas sharing the output directory elect a
+	// single leader, only the leader syncs and writes the configuration.
+	LeaderElection bool `yaml:"leaderElection" json:"leaderElection"`
+	// LeaseDuration is the time after which a standby takes over a lease
+	// that has not been renewed.
+	LeaseDuration time.Duration `yaml:"leaseDuration" json:"leaseDuration"`
+	// Identity identifies this replica in the lease, defaults to the hostname.
+	Identity string `yaml:"identity,omitempty" json:"identity,omitempty"`
+	// StatusAddr is the listen address of the status endpoint, disabled when empty.
+	StatusAddr string `yaml:"statusAddr,omitempty" json:"statusAddr,omitempty"`
+	// HistoryDir keeps the last generated configurations, defaults to
+	// {outputDir}/.history.
+	HistoryDir string `yaml:"historyDir,omitempty" json:"historyDir,omitempty"`
+	// HistoryLimit is the number of snapshots kept, 0 disables the history.
+	HistoryLimit int `yaml:"historyLimit" json:"historyLimit"`
+	// ConflictPolicy applies to routes matching the same requests with the
+	// same priority: warn, reject or require-priority.
+	ConflictPolicy string `yaml:"conflictPolicy" json:"conflictPolicy"`
+	// SecretsDir is where ${secret:name} label references are read from.
+	SecretsDir string `yaml:"secretsDir" json:"secretsDir"`
+	// ReferenceEnvPrefix is the prefix of the environment variables labels
+	// may read with ${env:NAME} references.
+	ReferenceEnvPrefix string `yaml:"referenceEnvPrefix" json:"referenceEnvPrefix"`
+	// ProjectSecrets lists the secrets, by name under SecretsDir, that the
+	// labels of each compose project or Swarm stack may read with
+	// ${secret:name} and ${file:path} references. The * key applies to
+	// every container or service.
+	ProjectSecrets map[string][]string `yaml:"projectSecrets,omitempty" json:"projectSecrets,omitempty"`
+	// PolicyFile is the path of the policy file restricting generated routes.
+	PolicyFile string `yaml:"policyFile,omitempty" json:"policyFile,omitempty"`
+	// Webhooks are notified when the generated routes change.
+	Webhooks []Webhook `yaml:"webhooks,omitempty" json:"webhooks,omitempty"`
+	// RouteDefaults holds goma.* labels applied to every discovered route,
+	// labels and project defaults take precedence.
+	RouteDefaults map[string]string `yaml:"routeDefaults,omitempty" json:"routeDefaults,omitempty"`
+	// ProjectDefaults holds goma.* labels applied to every route of a compose
+	// project or Swarm stack, keyed by project name. Labels of the routes and
+	// of goma.project_defaults containers take precedence.
+	ProjectDefaults map[string]map[string]string `yaml:"projectDefaults,omitempty" json:"projectDefaults,omitempty"`
+	// GroupByProject prefixes route names with their compose project or
+	// Swarm stack, so that services of different projects do not collide.
+	GroupByProject bool `yaml:"groupByProject" json:"groupByProject"`
+	// Traffic selects the active color of blue/green routes and the weight
+	// of canaries, keyed by route name. Controller containers take precedence.
+	Traffic map[string]Traffic `yaml:"traffic,omitempty" json:"traffic,omitempty"`
+	// DrainPeriod is the time the routes of a stopping container are kept
+	// out of rotation, or in maintenance, before they are removed. The
+	// goma.drain_period label overrides it, 0 disables draining.
+	DrainPeriod time.Duration `yaml:"drainPeriod" json:"drainPeriod"`
+	// LogLevel is the minimum level of the logs: debug, info, warning or error.
+	LogLevel string `yaml:"logLevel" json:"logLevel"`
+	// EntryPoints are the gateway entry points routes may listen on.
+	EntryPoints []string `yaml:"entryPoints,omitempty" json:"entryPoints,omitempty"`
+	// GatewayConfig is the path of the gateway goma.yml, the entry points it
+	// declares are added to EntryPoints.
+	GatewayConfig string `yaml:"gatewayConfig,omitempty" json:"gatewayConfig,omitempty"`
+	// TargetVersion is the configuration version of the gateway the output is
+	// generated for: 1 or 2.
+	TargetVersion string `yaml:"targetVersion" json:"targetVersion"`
+	// TraefikCompat translates the Traefik HTTP router and service labels of
+	// containers labeled traefik.enable=true without goma.enable.
+	TraefikCompat bool `yaml:"traefikCompat" json:"traefikCompat"`
 }
 
+// Webhook is an outgoing change notification endpoint.
+type Webhook struct {
+	URL string `yaml:"url" json:"url"`
+	// Secret signs the payload with HMAC-SHA256 when set.
+	Secret string `yaml:"secret,omitempty" json:"secret,omitempty"`
+	// MaxRetries is the number of retries after a failed delivery, 0 uses
+	// the default of 5 and -1 disables retries.
+	MaxRetries int           `yaml:"maxRetries,omitempty" json:"maxRetries,omitempty"`
+	Timeout    time.Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+// MarshalYAML hides the secret when printing the configuration.
+func (w Webhook) MarshalYAML() (any, error) {
+	type plain Webhook
+	if w.Secret != "" {
+		w.Secret = redacted
+	}
+	return plain(w), nil
+}
+
+const redacted = "******"
+
 func init() {
 	_ = godotenv.Load()
 }
-func New() *Config {
-	interval, err := time.ParseDuration(goutils.Env("GOMA_POLL_INTERVAL", "10s"))
+
+// Traffic holds the traffic settings of a route.
+type Traffic struct {
+	// Active is the active color of a blue/green route: blue or green.
+	Active string `yaml:"active,omitempty" json:"active,omitempty"`
+	// CanaryWeight overrides the weight of the canaries of the route, from 0 to 100.
+	CanaryWeight *int `yaml:"canaryWeight,omitempty" json:"canaryWeight,omitempty"`
+}
+
+// Default returns the built-in configuration.
+func Default() *Config {
+	hostname, _ := os.Hostname()
+	return &Config{
+		OutputDir:          "/etc/goma/providers",
+		PollInterval:       10 * time.Second,
+		LeaseDuration:      15 * time.Second,
+		Identity:           hostname,
+		HistoryLimit:       10,
+		ConflictPolicy:     "warn",
+		SecretsDir:         "/run/secrets",
+		LogLevel:           "info",
+		TargetVersion:      "2",
+		ReferenceEnvPrefix: "GOMA_REF_",
+	}
+}
+
+// Flags holds the command line flags that override the configuration.
+type Flags struct {
+	ConfigFile string
+
+	fs             *flag.FlagSet
+	outputDir      string
+	pollInterval   time.Duration
+	dockerHost     string
+	enableSwarm    bool
+	leaderElection bool
+	leaseDuration  time.Duration
+	identity       string
+	statusAddr     string
+	historyDir     string
+	historyLimit   int
+	policyFile     string
+	conflictPolicy string
+	groupByProject bool
+	logLevel       string
+	drainPeriod    time.Duration
+	entryPoints    string
+	gatewayConfig  string
+	targetVersion  string
+	traefikCompat  bool
+	envPrefix      string
+}
+
+// RegisterFlags registers the configuration flags on fs.
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{fs: fs}
+	fs.StringVar(&f.ConfigFile, "config", "", "Path to the provider config file (env: GOMA_CONFIG_FILE)")
+	fs.StringVar(&f.outputDir, "output-dir", "", "Output directory for generated routes (env: GOMA_OUTPUT_DIR)")
+	fs.DurationVar(&f.pollInterval, "poll-interval", 0, "Docker polling interval (env: GOMA_POLL_INTERVAL)")
+	fs.StringVar(&f.dockerHost, "docker-host", "", "Docker daemon address, overrides DOCKER_HOST (env: GOMA_DOCKER_HOST)")
+	fs.BoolVar(&f.enableSwarm, "swarm", false, "Enable Docker Swarm mode (env: GOMA_ENABLE_SWARM)")
+	fs.BoolVar(&f.leaderElection, "leader-election", false, "Enable leader election between replicas (env: GOMA_LEADER_ELECTION)")
+	fs.DurationVar(&f.leaseDuration, "lease-duration", 0, "Leader lease duration (env: GOMA_LEASE_DURATION)")
+	fs.StringVar(&f.identity, "identity", "", "Replica identity used for leader election (env: GOMA_IDENTITY)")
+	fs.StringVar(&f.statusAddr, "status-addr", "", "Listen address of the status endpoint, e.g. :8081 (env: GOMA_STATUS_ADDR)")
+	fs.StringVar(&f.historyDir, "history-dir", "", "Directory of generated configuration snapshots (env: GOMA_HISTORY_DIR)")
+	fs.StringVar(&f.conflictPolicy, "conflict-policy", "", "Route conflict policy: warn, reject or require-priority (env: GOMA_CONFLICT_POLICY)")
+	fs.StringVar(&f.policyFile, "policy", "", "Path to the policy file (env: GOMA_POLICY_FILE)")
+	fs.IntVar(&f.historyLimit, "history-limit", 0, "Number of snapshots kept, 0 disables the history (env: GOMA_HISTORY_LIMIT)")
+	fs.DurationVar(&f.drainPeriod, "drain-period", 0, "Time the routes of a stopping container are drained, 0 disables draining (env: GOMA_DRAIN_PERIOD)")
+	fs.StringVar(&f.logLevel, "log-level", "", "Log level: debug, info, warning or error (env: GOMA_LOG_LEVEL)")
+	fs.BoolVar(&f.groupByProject, "group-by-project", false, "Prefix route names with their compose project or stack (env: GOMA_GROUP_BY_PROJECT)")
+	fs.StringVar(&f.entryPoints, "entry-points", "", "Comma-separated gateway entry points routes may listen on (env: GOMA_ENTRY_POINTS)")
+	fs.StringVar(&f.gatewayConfig, "gateway-config", "", "Path of the gateway goma.yml declaring the entry points (env: GOMA_GATEWAY_CONFIG)")
+	fs.StringVar(&f.targetVersion, "target-version", "", "Gateway configuration version of the output: 1 or 2 (env: GOMA_TARGET_VERSION)")
+	fs.StringVar(&f.envPrefix, "reference-env-prefix", "", "Prefix of the environment variables labels may reference (env: GOMA_REFERENCE_ENV_PREFIX)")
+	fs.BoolVar(&f.traefikCompat, "traefik-compat", false, "Translate the Traefik labels of containers without goma labels (env: GOMA_TRAEFIK_COMPAT)")
+	return f
+}
+
+// Load builds the configuration from defaults, the config file, environment
+// variables and flags, and validates it. flags may be nil.
+func Load(flags *Flags) (*Config, error) {
+	cfg := Default()
+
+	configFile := os.Getenv("GOMA_CONFIG_FILE")
+	if flags != nil && flags.ConfigFile != "" {
+		configFile = flags.ConfigFile
+	}
+	if configFile != "" {
+		if err := cfg.loadFile(configFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+	if flags != nil {
+		flags.apply(cfg)
+	}
+	if cfg.HistoryDir == "" && cfg.OutputDir != "" {
+		cfg.HistoryDir = filepath.Join(cfg.OutputDir, ".history")
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks the configuration values.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.OutputDir == "" {
+		errs = append(errs, errors.New("outputDir must not be empty"))
+	}
+	if c.PollInterval < time.Second {
+		errs = append(errs, fmt.Errorf("pollInterval must be at least 1s, got %s", c.PollInterval))
+	}
+	switch c.ConflictPolicy {
+	case "warn", "reject", "require-priority":
+	default:
+		errs = append(errs, fmt.Errorf("conflictPolicy must be warn, reject or require-priority, got %q", c.ConflictPolicy))
+	}
+	if !filepath.IsAbs(c.SecretsDir) {
+		errs = append(errs, fmt.Errorf("secretsDir must be an absolute path, got %q", c.SecretsDir))
+	}
+	if c.ReferenceEnvPrefix == "" {
+		errs = append(errs, errors.New("referenceEnvPrefix must not be empty"))
+	}
+	for project, secrets := range c.ProjectSecrets {
+		for _, secret := range secrets {
+			if !filepath.IsLocal(secret) {
+				errs = append(errs, fmt.Errorf("projectSecrets[%s]: invalid secret name %q", project, secret))
+			}
+		}
+	}
+	if c.HistoryLimit < 0 {
+		errs = append(errs, fmt.Errorf("historyLimit must not be negative, got %d", c.HistoryLimit))
+	}
+	if c.LeaderElection {
+		if c.LeaseDuration < 3*time.Second {
+			errs = append(errs, fmt.Errorf("leaseDuration must be at least 3s, got %s", c.LeaseDuration))
+		}
+		if c.Identity == "" {
+			errs = append(errs, errors.New("identity must not be empty when leader election is enabled"))
+		}
+	}
+	for i, webhook := range c.Webhooks {
+		u, err := url.Parse(webhook.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("webhooks[%d]: invalid url %q", i, webhook.URL))
+		}
+		if webhook.MaxRetries < -1 {
+			errs = append(errs, fmt.Errorf("webhooks[%d]: maxRetries must be -1 or more, got %d", i, webhook.MaxRetries))
+		}
+		if webhook.Timeout < 0 {
+			errs = append(errs, fmt.Errorf("webhooks[%d]: timeout must not be negative", i))
+		}
+	}
+	if c.DrainPeriod < 0 {
+		errs = append(errs, fmt.Errorf("drainPeriod must not be negative, got %s", c.DrainPeriod))
+	}
+	switch c.TargetVersion {
+	case "1", "2":
+	default:
+		errs = append(errs, fmt.Errorf("targetVersion must be 1 or 2, got %q", c.TargetVersion))
+	}
+	switch c.LogLevel {
+	case "debug", "info", "warning", "error":
+	default:
+		errs = append(errs, fmt.Errorf("logLevel must be debug, info, warning or error, got %q", c.LogLevel))
+	}
+	for route, traffic := range c.Traffic {
+		if traffic.Active != "" && traffic.Active != "blue" && traffic.Active != "green" {
+			errs = append(errs, fmt.Errorf("traffic[%s]: active must be blue or green, got %q", route, traffic.Active))
+		}
+		if traffic.CanaryWeight != nil && (*traffic.CanaryWeight < 0 || *traffic.CanaryWeight > 100) {
+			errs = append(errs, fmt.Errorf("traffic[%s]: canaryWeight must be between 0 and 100, got %d", route, *traffic.CanaryWeight))
+		}
+	}
+	for i, entryPoint := range c.EntryPoints {
+		if entryPoint == "" || strings.ContainsAny(entryPoint, " ,") {
+			errs = append(errs, fmt.Errorf("entryPoints[%d]: invalid entry point name %q", i, entryPoint))
+		}
+	}
+	for key := range c.RouteDefaults {
+		if !strings.HasPrefix(key, "goma.") {
+			errs = append(errs, fmt.Errorf("routeDefaults: label %q must start with goma.", key))
+		}
+	}
+	for project, labels := range c.ProjectDefaults {
+		for key := range labels {
+			if !strings.HasPrefix(key, "goma.") {
+				errs = append(errs, fmt.Errorf("projectDefaults[%s]: label %q must start with goma.", project, key))
+			}
+		}
+	}
+	if c.StatusAddr != "" {
+		if _, _, err := net.SplitHostPort(c.StatusAddr); err != nil {
+			errs = append(errs, fmt.Errorf("invalid statusAddr %q: %w", c.StatusAddr, err))
+		}
+	}
+	if c.DockerHost != "" {
+		u, err := url.Parse(c.DockerHost)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid dockerHost %q: %w", c.DockerHost, err))
+		} else {
+			switch u.Scheme {
+			case "unix", "tcp", "npipe", "http", "https":
+			default:
+				errs = append(errs, fmt.Errorf("invalid dockerHost %q: unsupported scheme %q", c.DockerHost, u.Scheme))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		logger.Error("Failed to parse poll interval", "error", err)
-		interval = 10 * time.Second
+		return fmt.Errorf("failed to read config file: %w", err)
 	}
-	return &Config{
-		OutputDir:    goutils.Env("GOMA_OUTPUT_DIR", "/etc/goma/providers"),
-		PollInterval: interval,
-		EnableSwarm:  goutils.EnvBool("GOMA_ENABLE_SWARM", false),
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) loadEnv() error {
+	envString("GOMA_OUTPUT_DIR", &c.OutputDir)
+	envString("GOMA_DOCKER_HOST", &c.DockerHost)
+	envString("GOMA_IDENTITY", &c.Identity)
+	envString("GOMA_STATUS_ADDR", &c.StatusAddr)
+	envString("GOMA_HISTORY_DIR", &c.HistoryDir)
+	envString("GOMA_POLICY_FILE", &c.PolicyFile)
+	envString("GOMA_CONFLICT_POLICY", &c.ConflictPolicy)
+	envString("GOMA_SECRETS_DIR", &c.SecretsDir)
+	envString("GOMA_REFERENCE_ENV_PREFIX", &c.ReferenceEnvPrefix)
+	envString("GOMA_LOG_LEVEL", &c.LogLevel)
+	envString("GOMA_GATEWAY_CONFIG", &c.GatewayConfig)
+	envString("GOMA_TARGET_VERSION", &c.TargetVersion)
+	envList("GOMA_ENTRY_POINTS", &c.EntryPoints)
+	if value, ok := lookupEnv("GOMA_WEBHOOK_URL"); ok {
+		webhook := Webhook{URL: value}
+		envString("GOMA_WEBHOOK_SECRET", &webhook.Secret)
+		c.Webhooks = append(c.Webhooks, webhook)
+	}
+	return errors.Join(
+		envDuration("GOMA_POLL_INTERVAL", &c.PollInterval),
+		envBool("GOMA_ENABLE_SWARM", &c.EnableSwarm),
+		envBool("GOMA_LEADER_ELECTION", &c.LeaderElection),
+		envDuration("GOMA_LEASE_DURATION", &c.LeaseDuration),
+		envInt("GOMA_HISTORY_LIMIT", &c.HistoryLimit),
+		envBool("GOMA_GROUP_BY_PROJECT", &c.GroupByProject),
+		envDuration("GOMA_DRAIN_PERIOD", &c.DrainPeriod),
+		envBool("GOMA_TRAEFIK_COMPAT", &c.TraefikCompat),
+	)
+}
+
+// apply overrides the configuration with the flags set on the command line.
+func (f *Flags) apply(c *Config) {
+	f.fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "output-dir":
+			c.OutputDir = f.outputDir
+		case "poll-interval":
+			c.PollInterval = f.pollInterval
+		case "docker-host":
+			c.DockerHost = f.dockerHost
+		case "swarm":
+			c.EnableSwarm = f.enableSwarm
+		case "leader-election":
+			c.LeaderElection = f.leaderElection
+		case "lease-duration":
+			c.LeaseDuration = f.leaseDuration
+		case "identity":
+			c.Identity = f.identity
+		case "status-addr":
+			c.StatusAddr = f.statusAddr
+		case "history-dir":
+			c.HistoryDir = f.historyDir
+		case "history-limit":
+			c.HistoryLimit = f.historyLimit
+		case "policy":
+			c.PolicyFile = f.policyFile
+		case "conflict-policy":
+			c.ConflictPolicy = f.conflictPolicy
+		case "group-by-project":
+			c.GroupByProject = f.groupByProject
+		case "log-level":
+			c.LogLevel = f.logLevel
+		case "drain-period":
+			c.DrainPeriod = f.drainPeriod
+		case "entry-points":
+			c.EntryPoints = SplitList(f.entryPoints)
+		case "gateway-config":
+			c.GatewayConfig = f.gatewayConfig
+		case "target-version":
+			c.TargetVersion = f.targetVersion
+		case "traefik-compat":
+			c.TraefikCompat = f.traefikCompat
+		case "reference-env-prefix":
+			c.ReferenceEnvPrefix = f.envPrefix
+		}
+	})
+}
+
+// lookupEnv returns the value of a non-empty environment variable.
+func lookupEnv(key string) (string, bool) {
+	value, ok := os.LookupEnv(key)
+	return value, ok && value != ""
+}
+
+func envString(key string, target *string) {
+	if value, ok := lookupEnv(key); ok {
+		*target = value
 	}
+}
+
+func envList(key string, target *[]string) {
+	if value, ok := lookupEnv(key); ok {
+		*target = SplitList(value)
+	}
+}
+
+// SplitList splits a comma-separated list, ignoring empty items.
+func SplitList(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func envBool(key string, target *bool) error {
+	value, ok := lookupEnv(key)
+	if !ok {
+		return nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", key, err)
+	}
+	*target = parsed
+	return nil
+}
+
+func envInt(key string, target *int) error {
+	value, ok := lookupEnv(key)
+	if !ok {
+		return nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", key, err)
+	}
+	*target = parsed
+	return nil
+}
 
+func envDuration(key string, target *time.Duration) error {
+	value, ok := lookupEnv(key)
+	if !ok {
+		return nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", key, err)
+	}
+	*target = parsed
+	return nil
 }
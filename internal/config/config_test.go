@@ -0,0 +1,217 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// clearEnv unsets the provider environment variables for the test.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, entry := range os.Environ() {
+		if key, _, _ := strings.Cut(entry, "="); strings.HasPrefix(key, "GOMA_") {
+			t.Setenv(key, "")
+		}
+	}
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	clearEnv(t)
+	path := writeConfigFile(t, `
+outputDir: /file
+pollInterval: 20s
+logLevel: debug
+historyLimit: 3
+`)
+	t.Setenv("GOMA_CONFIG_FILE", path)
+	t.Setenv("GOMA_OUTPUT_DIR", "/env")
+	t.Setenv("GOMA_POLL_INTERVAL", "30s")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := RegisterFlags(fs)
+	if err := fs.Parse([]string{"--output-dir", "/flag"}); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checks := []struct {
+		name      string
+		got, want any
+	}{
+		{"flag over env", cfg.OutputDir, "/flag"},
+		{"env over file", cfg.PollInterval, 30 * time.Second},
+		{"file over default", cfg.LogLevel, "debug"},
+		{"file over default", cfg.HistoryLimit, 3},
+		{"default", cfg.ConflictPolicy, "warn"},
+		{"derived from the output directory", cfg.HistoryDir, filepath.Join("/flag", ".history")},
+	}
+	for _, check := range checks {
+		if check.got != check.want {
+			t.Errorf("%s: got %v, want %v", check.name, check.got, check.want)
+		}
+	}
+}
+
+func TestLoadFlagOverridesFile(t *testing.T) {
+	clearEnv(t)
+	path := writeConfigFile(t, "historyLimit: 3\nenableSwarm: true\n")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := RegisterFlags(fs)
+	// Flags set to their zero value still override
+	if err := fs.Parse([]string{"--config", path, "--history-limit", "0", "--swarm=false"}); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.HistoryLimit != 0 || cfg.EnableSwarm {
+		t.Errorf("historyLimit = %d, enableSwarm = %v, want the flag values", cfg.HistoryLimit, cfg.EnableSwarm)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		want string
+	}{
+		{"unknown file field", "outputDirectory: /tmp\n", nil, "field outputDirectory not found"},
+		{"invalid file value", "pollInterval: soon\n", nil, "failed to parse config file"},
+		{"invalid env bool", "", map[string]string{"GOMA_ENABLE_SWARM": "maybe"}, "invalid GOMA_ENABLE_SWARM"},
+		{"invalid env duration", "", map[string]string{"GOMA_POLL_INTERVAL": "10"}, "invalid GOMA_POLL_INTERVAL"},
+		{"invalid env int", "", map[string]string{"GOMA_HISTORY_LIMIT": "ten"}, "invalid GOMA_HISTORY_LIMIT"},
+		{"invalid value", "", map[string]string{"GOMA_LOG_LEVEL": "trace"}, "logLevel must be"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			if tt.file != "" {
+				t.Setenv("GOMA_CONFIG_FILE", writeConfigFile(t, tt.file))
+			}
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			_, err := Load(nil)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	clearEnv(t)
+	t.Setenv("GOMA_CONFIG_FILE", filepath.Join(t.TempDir(), "missing.yaml"))
+	if _, err := Load(nil); err == nil || !strings.Contains(err.Error(), "failed to read config file") {
+		t.Errorf("Load() error = %v, want a missing file error", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	weight := 150
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   string
+	}{
+		{"empty output directory", func(c *Config) { c.OutputDir = "" }, "outputDir must not be empty"},
+		{"short poll interval", func(c *Config) { c.PollInterval = time.Millisecond }, "pollInterval must be at least 1s"},
+		{"conflict policy", func(c *Config) { c.ConflictPolicy = "ignore" }, "conflictPolicy must be"},
+		{"relative secrets directory", func(c *Config) { c.SecretsDir = "secrets" }, "secretsDir must be an absolute path"},
+		{"empty reference prefix", func(c *Config) { c.ReferenceEnvPrefix = "" }, "referenceEnvPrefix must not be empty"},
+		{"project secret outside the secrets directory", func(c *Config) { c.ProjectSecrets = map[string][]string{"shop": {"../etc/passwd"}} }, "projectSecrets[shop]: invalid secret name"},
+		{"negative history limit", func(c *Config) { c.HistoryLimit = -1 }, "historyLimit must not be negative"},
+		{"short lease", func(c *Config) { c.LeaderElection, c.LeaseDuration = true, time.Second }, "leaseDuration must be at least 3s"},
+		{"leader without identity", func(c *Config) { c.LeaderElection, c.Identity = true, "" }, "identity must not be empty"},
+		{"webhook url", func(c *Config) { c.Webhooks = []Webhook{{URL: "ftp://example.com"}} }, "webhooks[0]: invalid url"},
+		{"webhook retries", func(c *Config) { c.Webhooks = []Webhook{{URL: "https://example.com", MaxRetries: -2}} }, "webhooks[0]: maxRetries must be -1 or more"},
+		{"webhook timeout", func(c *Config) { c.Webhooks = []Webhook{{URL: "https://example.com", Timeout: -time.Second}} }, "webhooks[0]: timeout must not be negative"},
+		{"negative drain period", func(c *Config) { c.DrainPeriod = -time.Second }, "drainPeriod must not be negative"},
+		{"target version", func(c *Config) { c.TargetVersion = "3" }, "targetVersion must be 1 or 2"},
+		{"log level", func(c *Config) { c.LogLevel = "trace" }, "logLevel must be"},
+		{"traffic color", func(c *Config) { c.Traffic = map[string]Traffic{"web": {Active: "red"}} }, "traffic[web]: active must be blue or green"},
+		{"traffic weight", func(c *Config) { c.Traffic = map[string]Traffic{"web": {CanaryWeight: &weight}} }, "traffic[web]: canaryWeight must be between 0 and 100"},
+		{"entry point name", func(c *Config) { c.EntryPoints = []string{"web,secure"} }, "entryPoints[0]: invalid entry point name"},
+		{"route default label", func(c *Config) { c.RouteDefaults = map[string]string{"port": "80"} }, `routeDefaults: label "port" must start with goma.`},
+		{"project default label", func(c *Config) { c.ProjectDefaults = map[string]map[string]string{"shop": {"port": "80"}} }, `projectDefaults[shop]: label "port" must start with goma.`},
+		{"status address", func(c *Config) { c.StatusAddr = "8081" }, "invalid statusAddr"},
+		{"docker host scheme", func(c *Config) { c.DockerHost = "ssh://host" }, `unsupported scheme "ssh"`},
+	}
+	if err := Default().Validate(); err != nil {
+		t.Fatalf("default configuration: %v", err)
+	}
+	noRetries := Default()
+	noRetries.Webhooks = []Webhook{{URL: "https://example.com", MaxRetries: -1}}
+	if err := noRetries.Validate(); err != nil {
+		t.Fatalf("webhook without retries: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.Identity = "test"
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintHidesWebhookSecret(t *testing.T) {
+	cfg := Default()
+	cfg.Webhooks = []Webhook{{URL: "https://example.com/hook", Secret: "s3cret"}}
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cret") || !strings.Contains(string(data), redacted) {
+		t.Errorf("printed configuration does not hide the secret:\n%s", data)
+	}
+	if cfg.Webhooks[0].Secret != "s3cret" {
+		t.Error("printing changed the secret")
+	}
+}
@@ -0,0 +1,184 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Conflict policies
+const (
+	// ConflictWarn reports conflicts and keeps all routes.
+	ConflictWarn = "warn"
+	// ConflictReject drops the route of the most recently created source.
+	ConflictReject = "reject"
+	// ConflictRequirePriority drops both routes until they get distinct priorities.
+	ConflictRequirePriority = "require-priority"
+)
+
+// routeConflict is a pair of enabled routes matching the same requests with
+// the same priority, so which one wins depends on gateway internals.
+type routeConflict struct {
+	older, newer *Route
+	// host and path are the most specific host and path both routes match
+	host string
+	path string
+}
+
+func (c routeConflict) String() string {
+	return fmt.Sprintf("route %q (%s) conflicts with route %q (%s) on host %s, path %s, priority %d",
+		c.newer.Name, c.newer.Source, c.older.Name, c.older.Source, c.host, c.path, c.newer.Priority)
+}
+
+// resolveConflicts detects overlapping routes and applies the conflict policy.
+func (p *Provider) resolveConflicts(routes []Route) []Route {
+	conflicts := findConflicts(routes)
+	if len(conflicts) == 0 {
+		return routes
+	}
+
+	rejected := make(map[*Route]bool)
+	for _, conflict := range conflicts {
+		switch p.config.ConflictPolicy {
+		case ConflictReject:
+			rejected[conflict.newer] = true
+			p.errorf(conflict.newer.Source, "", "%s: route %q rejected", conflict, conflict.newer.Name)
+		case ConflictRequirePriority:
+			rejected[conflict.newer] = true
+			rejected[conflict.older] = true
+			p.errorf(conflict.newer.Source, "", "%s: both routes rejected until they have distinct priorities", conflict)
+		default:
+			p.warnf(conflict.newer.Source, "", "%s", conflict)
+		}
+	}
+
+	kept := make([]Route, 0, len(routes))
+	for i := range routes {
+		if !rejected[&routes[i]] {
+			kept = append(kept, routes[i])
+		}
+	}
+	return kept
+}
+
+func findConflicts(routes []Route) []routeConflict {
+	// Compare routes from the oldest source to the newest
+	ordered := make([]*Route, len(routes))
+	for i := range routes {
+		ordered[i] = &routes[i]
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if !ordered[i].Created.Equal(ordered[j].Created) {
+			return ordered[i].Created.Before(ordered[j].Created)
+		}
+		return ordered[i].Name < ordered[j].Name
+	})
+
+	conflicts := make([]routeConflict, 0)
+	for i, older := range ordered {
+		if !older.Enabled {
+			continue
+		}
+		for _, newer := range ordered[i+1:] {
+			if !newer.Enabled || older.Priority != newer.Priority {
+				continue
+			}
+			path, overlap := overlappingPath(older.Path, newer.Path)
+			if !overlap {
+				continue
+			}
+			host, overlap := overlappingHost(older.Hosts, newer.Hosts)
+			if !overlap || !overlaps(older.Methods, newer.Methods) {
+				continue
+			}
+			conflicts = append(conflicts, routeConflict{older: older, newer: newer, host: host, path: path})
+		}
+	}
+	return conflicts
+}
+
+func normalizePath(path string) string {
+	if trimmed := strings.TrimRight(path, "/"); trimmed != "" {
+		return trimmed
+	}
+	return "/"
+}
+
+// overlappingPath returns the most specific path matched by both paths. A
+// route matches its path and every path below it, segment by segment: /api
+// overlaps /api/v1 but not /apis.
+func overlappingPath(a, b string) (string, bool) {
+	a, b = normalizePath(a), normalizePath(b)
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	if a == "/" || a == b || strings.HasPrefix(b, a+"/") {
+		return b, true
+	}
+	return "", false
+}
+
+// overlappingHost returns the most specific host matched by both lists, an
+// empty list matches any host. Wildcards such as *.example.com match their
+// subdomains.
+func overlappingHost(a, b []string) (string, bool) {
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return "*", true
+	case len(a) == 0:
+		return b[0], true
+	case len(b) == 0:
+		return a[0], true
+	}
+	for _, x := range a {
+		for _, y := range b {
+			x, y := strings.ToLower(x), strings.ToLower(y)
+			switch {
+			case x == y, matchHostPattern(x, y):
+				return y, true
+			case matchHostPattern(y, x):
+				return x, true
+			}
+		}
+	}
+	return "", false
+}
+
+// overlaps reports whether two value lists share a value, an empty list matches any value.
+func overlaps(a, b []string) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return true
+	}
+	for _, x := range a {
+		for _, y := range b {
+			if strings.EqualFold(x, y) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jkaninda/goma-docker-provider/internal/config"
+)
+
+func TestOverlappingPath(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want string
+		ok   bool
+	}{
+		{"/api", "/api", "/api", true},
+		{"/api/", "/api", "/api", true},
+		{"/api", "/api/v1", "/api/v1", true},
+		{"/api/v1/", "/api", "/api/v1", true},
+		{"/", "/api", "/api", true},
+		{"", "/api", "/api", true},
+		{"/api", "/apis", "", false},
+		{"/api/v1", "/api/v2", "", false},
+		{"/api/v1", "/api", "/api/v1", true},
+	}
+	for _, tt := range tests {
+		got, ok := overlappingPath(tt.a, tt.b)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("overlappingPath(%q, %q) = %q, %v, want %q, %v", tt.a, tt.b, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestOverlappingHost(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []string
+		want string
+		ok   bool
+	}{
+		{"both without hosts", nil, nil, "*", true},
+		{"one without hosts", nil, []string{"a.example.com"}, "a.example.com", true},
+		{"same host", []string{"A.example.com"}, []string{"a.example.com"}, "a.example.com", true},
+		{"wildcard and subdomain", []string{"*.example.com"}, []string{"a.example.com"}, "a.example.com", true},
+		{"subdomain and wildcard", []string{"a.example.com"}, []string{"*.example.com"}, "a.example.com", true},
+		{"nested wildcards", []string{"*.example.com"}, []string{"*.eu.example.com"}, "*.eu.example.com", true},
+		{"wildcard and apex", []string{"*.example.com"}, []string{"example.com"}, "", false},
+		{"distinct hosts", []string{"a.example.com"}, []string{"b.example.com"}, "", false},
+		{"one common host", []string{"a.example.com", "b.example.com"}, []string{"c.example.com", "b.example.com"}, "b.example.com", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := overlappingHost(tt.a, tt.b)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("overlappingHost(%v, %v) = %q, %v, want %q, %v", tt.a, tt.b, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestResolveConflicts(t *testing.T) {
+	now := time.Now()
+	older := Route{Name: "api", Source: "api-1", Path: "/api", Hosts: []string{"*.example.com"}, Enabled: true, Created: now.Add(-time.Hour)}
+	tests := []struct {
+		name   string
+		newer  Route
+		policy string
+		want   []string
+	}{
+		{
+			name:   "prefix and wildcard overlap, warn",
+			newer:  Route{Name: "api-v1", Source: "api-2", Path: "/api/v1", Hosts: []string{"a.example.com"}, Enabled: true, Created: now},
+			policy: ConflictWarn,
+			want:   []string{"api", "api-v1"},
+		},
+		{
+			name:   "prefix and wildcard overlap, reject",
+			newer:  Route{Name: "api-v1", Source: "api-2", Path: "/api/v1", Hosts: []string{"a.example.com"}, Enabled: true, Created: now},
+			policy: ConflictReject,
+			want:   []string{"api"},
+		},
+		{
+			name:   "route without hosts, require priority",
+			newer:  Route{Name: "api-v1", Source: "api-2", Path: "/api/v1", Enabled: true, Created: now},
+			policy: ConflictRequirePriority,
+			want:   nil,
+		},
+		{
+			name:   "distinct priorities",
+			newer:  Route{Name: "api-v1", Source: "api-2", Path: "/api/v1", Priority: 10, Enabled: true, Created: now},
+			policy: ConflictReject,
+			want:   []string{"api", "api-v1"},
+		},
+		{
+			name:   "sibling paths",
+			newer:  Route{Name: "apis", Source: "api-2", Path: "/apis", Enabled: true, Created: now},
+			policy: ConflictReject,
+			want:   []string{"api", "apis"},
+		},
+		{
+			name:   "no methods match every method",
+			newer:  Route{Name: "api-v1", Source: "api-2", Path: "/api/v1", Methods: []string{"POST"}, Enabled: true, Created: now},
+			policy: ConflictReject,
+			want:   []string{"api"},
+		},
+		{
+			name:   "disabled route",
+			newer:  Route{Name: "api-v1", Source: "api-2", Path: "/api/v1", Created: now},
+			policy: ConflictReject,
+			want:   []string{"api", "api-v1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.ConflictPolicy = tt.policy
+			p := NewProvider(cfg)
+			kept := p.resolveConflicts([]Route{older, tt.newer})
+			var got []string
+			for _, route := range kept {
+				got = append(got, route.Name)
+			}
+			if !equalStrings(got, tt.want) {
+				t.Errorf("kept routes = %v, want %v (diagnostics: %v)", got, tt.want, p.diagnostics)
+			}
+		})
+	}
+}
+
+func TestConflictMessage(t *testing.T) {
+	p := NewProvider(config.Default())
+	p.resolveConflicts([]Route{
+		{Name: "api", Source: "api-1", Path: "/api", Hosts: []string{"*.example.com"}, Enabled: true},
+		{Name: "api-v1", Source: "api-2", Path: "/api/v1", Hosts: []string{"a.example.com"}, Enabled: true, Created: time.Now()},
+	})
+	want := `route "api-v1" (api-2) conflicts with route "api" (api-1) on host a.example.com, path /api/v1, priority 0`
+	if len(p.diagnostics) != 1 || p.diagnostics[0].Message != want {
+		t.Errorf("diagnostics = %v, want %q", p.diagnostics, want)
+	}
+}
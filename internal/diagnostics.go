@@ -0,0 +1,130 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jkaninda/logger"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+// Diagnostic describes a problem found while parsing labels, or a change
+// made to a route by the policy.
+type Diagnostic struct {
+	Severity Severity `yaml:"severity" json:"severity"`
+	// Source is the container or service the labels belong to.
+	Source string `yaml:"source" json:"source"`
+	// Label is the offending label key, if any.
+	Label   string `yaml:"label,omitempty" json:"label,omitempty"`
+	Message string `yaml:"message" json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	if d.Label != "" {
+		return fmt.Sprintf("%s: %s: %s: %s", d.Severity, d.Source, d.Label, d.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", d.Severity, d.Source, d.Message)
+}
+
+func (p *Provider) errorf(source, label, format string, args ...any) {
+	p.diagnostics = append(p.diagnostics, Diagnostic{
+		Severity: SeverityError,
+		Source:   source,
+		Label:    label,
+		Message:  p.redact(fmt.Sprintf(format, args...)),
+	})
+}
+
+func (p *Provider) warnf(source, label, format string, args ...any) {
+	p.diagnostics = append(p.diagnostics, Diagnostic{
+		Severity: SeverityWarning,
+		Source:   source,
+		Label:    label,
+		Message:  p.redact(fmt.Sprintf(format, args...)),
+	})
+}
+
+func (p *Provider) infof(source, label, format string, args ...any) {
+	p.diagnostics = append(p.diagnostics, Diagnostic{
+		Severity: SeverityInfo,
+		Source:   source,
+		Label:    label,
+		Message:  p.redact(fmt.Sprintf(format, args...)),
+	})
+}
+
+// logDiagnostics logs the diagnostics of the last sync, only when they changed
+// since the previous one to avoid flooding the logs on every poll.
+func (p *Provider) logDiagnostics() {
+	data, _ := json.Marshal(p.diagnostics)
+	hash := sha256.Sum256(data)
+	current := hex.EncodeToString(hash[:])
+	if current == p.lastDiagnosticsHash {
+		return
+	}
+	p.lastDiagnosticsHash = current
+
+	for _, d := range p.diagnostics {
+		switch d.Severity {
+		case SeverityError:
+			logger.Error("Invalid label configuration", "source", d.Source, "label", d.Label, "message", d.Message)
+		case SeverityWarning:
+			logger.Warn("Label configuration warning", "source", d.Source, "label", d.Label, "message", d.Message)
+		default:
+			logger.Info("Route policy applied", "source", d.Source, "label", d.Label, "message", d.Message)
+		}
+	}
+}
+
+// hasWarnings reports whether any diagnostic has error or warning severity.
+func hasWarnings(diagnostics []Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity != SeverityInfo {
+			return true
+		}
+	}
+	return false
+}
+
+// hasErrors reports whether any diagnostic has error severity.
+func hasErrors(diagnostics []Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
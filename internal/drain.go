@@ -0,0 +1,190 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/jkaninda/logger"
+)
+
+// drainPeriodLabel overrides the drain period of a container.
+const drainPeriodLabel = "goma.drain_period"
+
+// drainingContainer is a stopping container whose routes are taken out of
+// rotation until its drain period ends.
+type drainingContainer struct {
+	name   string
+	routes []Route
+	until  time.Time
+}
+
+// watchEvents forwards the start and stop events of the containers labeled
+// label=true to out, reconnecting when the event stream fails.
+func (p *Provider) watchEvents(ctx context.Context, out chan<- events.Message, label string) {
+	for {
+		messages, errs := p.dockerClient.Events(ctx, events.ListOptions{
+			Filters: filters.NewArgs(
+				filters.Arg("type", string(events.ContainerEventType)),
+				filters.Arg("event", string(events.ActionStart)),
+				filters.Arg("event", string(events.ActionKill)),
+				filters.Arg("event", string(events.ActionStop)),
+				filters.Arg("event", string(events.ActionDie)),
+				filters.Arg("label", label+"=true"),
+			),
+		})
+
+	stream:
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message := <-messages:
+				select {
+				case out <- message:
+				case <-ctx.Done():
+					return
+				}
+			case err := <-errs:
+				logger.Warn("Docker event stream failed, reconnecting", "error", err)
+				break stream
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// handleEvent starts draining the routes of a stopping container, or stops
+// draining them when the container starts again.
+func (p *Provider) handleEvent(message events.Message) {
+	id := message.Actor.ID
+	name := message.Actor.Attributes["name"]
+	switch message.Action {
+	case events.ActionStart:
+		if _, ok := p.draining[id]; ok {
+			delete(p.draining, id)
+			logger.Info("Container started again, draining cancelled", "container", name)
+		}
+	case events.ActionKill, events.ActionStop, events.ActionDie:
+		if message.Action == events.ActionKill && !stoppingSignal(message.Actor.Attributes["signal"]) {
+			// Signals such as HUP or USR1 reload a process, they do not stop it
+			return
+		}
+		if _, ok := p.draining[id]; ok {
+			return
+		}
+		routes := p.containerRoutes[id]
+		period := p.drainPeriod(name, message.Actor.Attributes[drainPeriodLabel])
+		if period <= 0 || len(routes) == 0 {
+			return
+		}
+		if p.draining == nil {
+			p.draining = make(map[string]*drainingContainer)
+		}
+		p.draining[id] = &drainingContainer{name: name, routes: routes, until: time.Now().Add(period)}
+		logger.Info("Draining container routes", "container", name, "routes", len(routes), "period", period)
+	}
+}
+
+// stoppingSignal reports whether a kill event signal stops the container:
+// TERM, INT or KILL, by number as Docker reports them or by name.
+func stoppingSignal(signal string) bool {
+	switch strings.TrimPrefix(strings.ToUpper(signal), "SIG") {
+	case "TERM", "INT", "KILL", "15", "2", "9":
+		return true
+	}
+	return false
+}
+
+// drainPeriod returns the drain period of a container, from its label or
+// the configuration.
+func (p *Provider) drainPeriod(name, label string) time.Duration {
+	if label == "" {
+		return p.config.DrainPeriod
+	}
+	period, err := time.ParseDuration(label)
+	if err != nil {
+		logger.Warn("Invalid drain period, using the default", "container", name, "value", label, "error", err)
+		return p.config.DrainPeriod
+	}
+	return period
+}
+
+// drainingRoutes returns the routes of the draining containers that are
+// still needed and forgets the containers whose drain period ended.
+//
+// A draining backend is removed from rotation when other containers serve
+// the same route, as canaries, blue/green colors or replicas. The route is
+// otherwise kept in maintenance until the end of the drain period, so that
+// clients get a clean 503 instead of a missing route while the container
+// finishes its requests.
+func (p *Provider) drainingRoutes(live []Route) []Route {
+	served := make(map[string]bool, len(live))
+	for _, route := range live {
+		if route.CanaryOf == "" {
+			served[routeKey(route.Project, route.Name)] = true
+		}
+	}
+
+	ids := make([]string, 0, len(p.draining))
+	for id := range p.draining {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return p.draining[ids[i]].name < p.draining[ids[j]].name
+	})
+
+	now := time.Now()
+	routes := make([]Route, 0)
+	for _, id := range ids {
+		container := p.draining[id]
+		if now.After(container.until) {
+			delete(p.draining, id)
+			delete(p.containerRoutes, id)
+			logger.Info("Drain period ended, container routes removed", "container", container.name)
+			continue
+		}
+		for _, route := range container.routes {
+			key := routeKey(route.Project, route.Name)
+			if route.CanaryOf != "" || served[key] {
+				continue
+			}
+			served[key] = true
+			route.Maintenance = Maintenance{Enabled: true}
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
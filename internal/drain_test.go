@@ -0,0 +1,83 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/jkaninda/goma-docker-provider/internal/config"
+)
+
+func TestHandleEventDrain(t *testing.T) {
+	tests := []struct {
+		name   string
+		action events.Action
+		signal string
+		want   bool
+	}{
+		{"stop", events.ActionStop, "", true},
+		{"die", events.ActionDie, "", true},
+		{"kill with SIGTERM", events.ActionKill, "15", true},
+		{"kill with SIGINT", events.ActionKill, "2", true},
+		{"kill with SIGKILL", events.ActionKill, "9", true},
+		{"kill with a signal name", events.ActionKill, "SIGTERM", true},
+		{"kill with SIGHUP", events.ActionKill, "1", false},
+		{"kill with SIGUSR1", events.ActionKill, "10", false},
+		{"kill with a reload signal name", events.ActionKill, "HUP", false},
+		{"kill without signal", events.ActionKill, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.DrainPeriod = time.Minute
+			p := NewProvider(cfg)
+			p.containerRoutes = map[string][]Route{"abc": {{Name: "web"}}}
+			p.handleEvent(events.Message{
+				Action: tt.action,
+				Actor: events.Actor{ID: "abc", Attributes: map[string]string{
+					"name":   "web-1",
+					"signal": tt.signal,
+				}},
+			})
+			if _, got := p.draining["abc"]; got != tt.want {
+				t.Errorf("draining = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleEventStartCancelsDrain(t *testing.T) {
+	cfg := config.Default()
+	cfg.DrainPeriod = time.Minute
+	p := NewProvider(cfg)
+	p.containerRoutes = map[string][]Route{"abc": {{Name: "web"}}}
+	p.handleEvent(events.Message{Action: events.ActionStop, Actor: events.Actor{ID: "abc"}})
+	p.handleEvent(events.Message{Action: events.ActionStart, Actor: events.Actor{ID: "abc"}})
+	if len(p.draining) != 0 {
+		t.Errorf("draining = %v, want none", p.draining)
+	}
+}
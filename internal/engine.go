@@ -0,0 +1,157 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/api/types/system"
+	"github.com/jkaninda/logger"
+)
+
+// dockerAPI is the subset of the Docker client used by the provider. It is
+// served by Docker as well as by Podman through its Docker-compatible API.
+type dockerAPI interface {
+	Info(ctx context.Context) (system.Info, error)
+	ServerVersion(ctx context.Context) (types.Version, error)
+	ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
+	ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error)
+	ServiceList(ctx context.Context, options swarm.ServiceListOptions) ([]swarm.Service, error)
+	Events(ctx context.Context, options events.ListOptions) (<-chan events.Message, <-chan error)
+	Close() error
+}
+
+// socketCandidates returns the sockets probed when no Docker host is
+// configured, Docker first, then rootful and rootless Podman.
+func socketCandidates() []string {
+	candidates := []string{"/var/run/docker.sock", "/run/podman/podman.sock"}
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		candidates = append(candidates, filepath.Join(runtimeDir, "podman", "podman.sock"))
+	}
+	return append(candidates, fmt.Sprintf("/run/user/%d/podman/podman.sock", os.Getuid()))
+}
+
+// detectSocket returns the address of the first existing socket, or an empty
+// string when DOCKER_HOST is set or no socket is found.
+func detectSocket() string {
+	if os.Getenv("DOCKER_HOST") != "" {
+		return ""
+	}
+	for _, candidate := range socketCandidates() {
+		if info, err := os.Stat(candidate); err == nil && info.Mode()&os.ModeSocket != 0 {
+			return "unix://" + candidate
+		}
+	}
+	return ""
+}
+
+// isPodman reports whether the engine behind the API is Podman.
+func isPodman(version types.Version) bool {
+	for _, component := range version.Components {
+		if strings.Contains(strings.ToLower(component.Name), "podman") {
+			return true
+		}
+	}
+	return false
+}
+
+// containerName returns the name of a container. Podman may return
+// containers without names, or names without the leading slash.
+func containerName(c container.Summary) string {
+	for _, name := range c.Names {
+		if name = strings.TrimPrefix(name, "/"); name != "" {
+			return name
+		}
+	}
+	if len(c.ID) > 12 {
+		return c.ID[:12]
+	}
+	return c.ID
+}
+
+// networkOwner returns the id of the container owning the network namespace
+// of c, as for containers in a Podman pod, which share the namespace of the
+// pod infra container, or containers using network_mode: container:x.
+func (p *Provider) networkOwner(ctx context.Context, c container.Summary) string {
+	mode := c.HostConfig.NetworkMode
+	if !strings.HasPrefix(mode, "container:") && p.isPodman {
+		// Podman does not always report the network mode when listing
+		inspect, err := p.dockerClient.ContainerInspect(ctx, c.ID)
+		if err != nil {
+			logger.Debug("Failed to inspect container", "container", containerName(c), "error", err)
+			return ""
+		}
+		if inspect.ContainerJSONBase != nil && inspect.HostConfig != nil {
+			mode = string(inspect.HostConfig.NetworkMode)
+		}
+	}
+	owner, _ := strings.CutPrefix(mode, "container:")
+	if owner == mode {
+		return ""
+	}
+	return owner
+}
+
+// resolveTargetHosts maps containers sharing another container's network
+// namespace to the name of that container, which is the one reachable on the
+// network.
+func (p *Provider) resolveTargetHosts(ctx context.Context, containers []container.Summary) map[string]string {
+	hosts := make(map[string]string)
+	names := make(map[string]string)
+	for _, c := range containers {
+		names[c.ID] = containerName(c)
+	}
+
+	for _, c := range containers {
+		owner := p.networkOwner(ctx, c)
+		if owner == "" {
+			continue
+		}
+		name, ok := names[owner]
+		if !ok {
+			// The owner, such as a pod infra container, has no goma labels
+			inspect, err := p.dockerClient.ContainerInspect(ctx, owner)
+			if err != nil {
+				logger.Error("Failed to inspect network namespace owner", "container", containerName(c), "owner", owner, "error", err)
+				continue
+			}
+			name = containerName(container.Summary{ID: owner})
+			if inspect.ContainerJSONBase != nil && inspect.Name != "" {
+				name = strings.TrimPrefix(inspect.Name, "/")
+			}
+			names[owner] = name
+		}
+		hosts[c.ID] = name
+	}
+	return hosts
+}
@@ -0,0 +1,346 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/api/types/system"
+	"github.com/jkaninda/goma-docker-provider/internal/config"
+)
+
+// fakeDocker serves a fixed set of containers and services as Docker or
+// Podman would.
+type fakeDocker struct {
+	info       system.Info
+	version    types.Version
+	versionErr error
+	containers []container.Summary
+	services   []swarm.Service
+	// inspect holds the inspect responses, by container ID
+	inspect map[string]container.InspectResponse
+	// inspected records the inspected container IDs
+	inspected []string
+}
+
+func (f *fakeDocker) Info(context.Context) (system.Info, error) {
+	return f.info, nil
+}
+
+func (f *fakeDocker) ServerVersion(context.Context) (types.Version, error) {
+	return f.version, f.versionErr
+}
+
+func (f *fakeDocker) ContainerList(_ context.Context, options container.ListOptions) ([]container.Summary, error) {
+	list := make([]container.Summary, 0, len(f.containers))
+	for _, c := range f.containers {
+		if matchLabelFilters(options.Filters.Get("label"), c.Labels) {
+			list = append(list, c)
+		}
+	}
+	return list, nil
+}
+
+func (f *fakeDocker) ContainerInspect(_ context.Context, id string) (container.InspectResponse, error) {
+	f.inspected = append(f.inspected, id)
+	inspect, ok := f.inspect[id]
+	if !ok {
+		return container.InspectResponse{}, errors.New("no such container: " + id)
+	}
+	return inspect, nil
+}
+
+func (f *fakeDocker) ServiceList(_ context.Context, options swarm.ServiceListOptions) ([]swarm.Service, error) {
+	list := make([]swarm.Service, 0, len(f.services))
+	for _, s := range f.services {
+		if matchLabelFilters(options.Filters.Get("label"), s.Spec.Labels) {
+			list = append(list, s)
+		}
+	}
+	return list, nil
+}
+
+func (f *fakeDocker) Events(context.Context, events.ListOptions) (<-chan events.Message, <-chan error) {
+	return make(chan events.Message), make(chan error)
+}
+
+func (f *fakeDocker) Close() error {
+	return nil
+}
+
+// matchLabelFilters reports whether labels match every key=value filter.
+func matchLabelFilters(filters []string, labels map[string]string) bool {
+	for _, filter := range filters {
+		key, value, _ := strings.Cut(filter, "=")
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func inspectResponse(id, name, networkMode string) container.InspectResponse {
+	return container.InspectResponse{ContainerJSONBase: &container.ContainerJSONBase{
+		ID:         id,
+		Name:       name,
+		HostConfig: &container.HostConfig{NetworkMode: container.NetworkMode(networkMode)},
+	}}
+}
+
+func TestContainerName(t *testing.T) {
+	tests := []struct {
+		name      string
+		container container.Summary
+		want      string
+	}{
+		{"docker name", container.Summary{ID: "0123456789abcdef", Names: []string{"/web-1"}}, "web-1"},
+		{"podman name without slash", container.Summary{ID: "0123456789abcdef", Names: []string{"web-1"}}, "web-1"},
+		{"first non-empty name", container.Summary{ID: "0123456789abcdef", Names: []string{"/", "web-1"}}, "web-1"},
+		{"nameless container", container.Summary{ID: "0123456789abcdef"}, "0123456789ab"},
+		{"nameless container with a short id", container.Summary{ID: "abc"}, "abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := containerName(tt.container); got != tt.want {
+				t.Errorf("containerName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectEngine(t *testing.T) {
+	tests := []struct {
+		name    string
+		docker  *fakeDocker
+		podman  bool
+		swarmOn bool
+	}{
+		{
+			name:   "docker",
+			docker: &fakeDocker{version: types.Version{Components: []types.ComponentVersion{{Name: "Engine"}, {Name: "containerd"}}}},
+		},
+		{
+			name:   "podman engine component",
+			docker: &fakeDocker{version: types.Version{Components: []types.ComponentVersion{{Name: "Podman Engine"}}}},
+			podman: true,
+		},
+		{
+			name:   "podman conmon component",
+			docker: &fakeDocker{version: types.Version{Components: []types.ComponentVersion{{Name: "Engine"}, {Name: "podman"}}}},
+			podman: true,
+		},
+		{
+			name:   "no components",
+			docker: &fakeDocker{},
+		},
+		{
+			name:   "version error",
+			docker: &fakeDocker{versionErr: errors.New("not implemented")},
+		},
+		{
+			name:    "swarm",
+			docker:  &fakeDocker{info: system.Info{Swarm: swarm.Info{LocalNodeState: swarm.LocalNodeStateActive}}},
+			swarmOn: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewProvider(config.Default())
+			p.dockerClient = tt.docker
+			if err := p.detectEngine(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if p.isPodman != tt.podman || p.isSwarmMode != tt.swarmOn {
+				t.Errorf("podman = %v, swarm = %v, want %v, %v", p.isPodman, p.isSwarmMode, tt.podman, tt.swarmOn)
+			}
+		})
+	}
+}
+
+func TestNetworkOwner(t *testing.T) {
+	tests := []struct {
+		name      string
+		podman    bool
+		container container.Summary
+		inspect   map[string]container.InspectResponse
+		want      string
+		inspected bool
+	}{
+		{
+			name:      "container network mode",
+			container: container.Summary{ID: "app", HostConfig: summaryHostConfig("container:infra")},
+			want:      "infra",
+		},
+		{
+			name:      "bridge network on docker is not inspected",
+			container: container.Summary{ID: "app", HostConfig: summaryHostConfig("bridge")},
+		},
+		{
+			name:      "podman pod member without listed network mode",
+			podman:    true,
+			container: container.Summary{ID: "app"},
+			inspect:   map[string]container.InspectResponse{"app": inspectResponse("app", "/app", "container:infra")},
+			want:      "infra",
+			inspected: true,
+		},
+		{
+			name:      "podman container on a network",
+			podman:    true,
+			container: container.Summary{ID: "app"},
+			inspect:   map[string]container.InspectResponse{"app": inspectResponse("app", "app", "bridge")},
+			inspected: true,
+		},
+		{
+			name:      "podman inspect without host config",
+			podman:    true,
+			container: container.Summary{ID: "app"},
+			inspect:   map[string]container.InspectResponse{"app": {ContainerJSONBase: &container.ContainerJSONBase{ID: "app"}}},
+			inspected: true,
+		},
+		{
+			name:      "podman inspect without details",
+			podman:    true,
+			container: container.Summary{ID: "app"},
+			inspect:   map[string]container.InspectResponse{"app": {}},
+			inspected: true,
+		},
+		{
+			name:      "podman inspect error",
+			podman:    true,
+			container: container.Summary{ID: "app"},
+			inspected: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docker := &fakeDocker{inspect: tt.inspect}
+			p := NewProvider(config.Default())
+			p.dockerClient = docker
+			p.isPodman = tt.podman
+			if got := p.networkOwner(context.Background(), tt.container); got != tt.want {
+				t.Errorf("networkOwner() = %q, want %q", got, tt.want)
+			}
+			if inspected := len(docker.inspected) > 0; inspected != tt.inspected {
+				t.Errorf("inspected = %v, want %v", inspected, tt.inspected)
+			}
+		})
+	}
+}
+
+func TestResolveTargetHosts(t *testing.T) {
+	docker := &fakeDocker{inspect: map[string]container.InspectResponse{
+		// Pod infra container, without goma labels so not listed
+		"infra": inspectResponse("infra", "shop-pod-infra", ""),
+	}}
+	containers := []container.Summary{
+		{ID: "web", Names: []string{"web"}, HostConfig: summaryHostConfig("container:infra")},
+		{ID: "api", Names: []string{"api"}, HostConfig: summaryHostConfig("container:infra")},
+		{ID: "db", Names: []string{"/db"}, HostConfig: summaryHostConfig("bridge")},
+		{ID: "0123456789abcdef", HostConfig: summaryHostConfig("bridge")},
+		{ID: "sidecar", Names: []string{"sidecar"}, HostConfig: summaryHostConfig("container:0123456789abcdef")},
+		{ID: "orphan", Names: []string{"orphan"}, HostConfig: summaryHostConfig("container:gone")},
+	}
+	p := NewProvider(config.Default())
+	p.dockerClient = docker
+	hosts := p.resolveTargetHosts(context.Background(), containers)
+
+	want := map[string]string{
+		"web":     "shop-pod-infra",
+		"api":     "shop-pod-infra",
+		"sidecar": "0123456789ab",
+	}
+	if len(hosts) != len(want) {
+		t.Errorf("hosts = %v, want %v", hosts, want)
+	}
+	for id, name := range want {
+		if hosts[id] != name {
+			t.Errorf("host of %s = %q, want %q", id, hosts[id], name)
+		}
+	}
+	// The infra container is inspected once, listed owners are not inspected
+	if strings.Join(docker.inspected, ",") != "infra,gone" {
+		t.Errorf("inspected = %v, want [infra gone]", docker.inspected)
+	}
+}
+
+func TestPodContainerRoutes(t *testing.T) {
+	docker := &fakeDocker{
+		version: types.Version{Components: []types.ComponentVersion{{Name: "Podman Engine"}}},
+		containers: []container.Summary{{
+			ID:     "app",
+			Names:  []string{"shop-app"},
+			Labels: map[string]string{"goma.enable": "true", "goma.port": "8080"},
+		}},
+		inspect: map[string]container.InspectResponse{
+			"app":   inspectResponse("app", "shop-app", "container:infra"),
+			"infra": inspectResponse("infra", "shop-infra", ""),
+		},
+	}
+	p := NewProvider(config.Default())
+	p.dockerClient = docker
+	if err := p.detectEngine(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	config, err := p.buildConfiguration(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Routes) != 1 {
+		t.Fatalf("got %d routes, want 1: %v", len(config.Routes), p.diagnostics)
+	}
+	if route := config.Routes[0]; route.Name != "shop-app" || route.Target != "http://shop-infra:8080" {
+		t.Errorf("route %q targets %q, want shop-app targeting http://shop-infra:8080", route.Name, route.Target)
+	}
+}
+
+func summaryHostConfig(networkMode string) struct {
+	NetworkMode string            `json:",omitempty"`
+	Annotations map[string]string `json:",omitempty"`
+} {
+	c := container.Summary{}
+	c.HostConfig.NetworkMode = networkMode
+	return c.HostConfig
+}
+
+func TestResolveTargetHostsNamelessOwner(t *testing.T) {
+	docker := &fakeDocker{inspect: map[string]container.InspectResponse{
+		"fedcba9876543210": {},
+	}}
+	p := NewProvider(config.Default())
+	p.dockerClient = docker
+	hosts := p.resolveTargetHosts(context.Background(), []container.Summary{
+		{ID: "web", Names: []string{"web"}, HostConfig: summaryHostConfig("container:fedcba9876543210")},
+	})
+	if hosts["web"] != "fedcba987654" {
+		t.Errorf("host of web = %q, want the short id of its owner", hosts["web"])
+	}
+}
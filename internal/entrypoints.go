@@ -0,0 +1,135 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/jkaninda/goma-docker-provider/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// gatewayFile is the part of the gateway goma.yml declaring the entry points.
+type gatewayFile struct {
+	Gateway struct {
+		EntryPoints map[string]yaml.Node `yaml:"entryPoints"`
+	} `yaml:"gateway"`
+}
+
+// gatewayEntryPoint is an entry point of the gateway goma.yml.
+type gatewayEntryPoint struct {
+	Address string `yaml:"address"`
+}
+
+// loadEntryPoints returns the entry points routes may listen on, from the
+// provider configuration and the gateway configuration file, and the listen
+// addresses the gateway file declares for them, by name. It returns nil
+// when neither declares entry points, entry points are not checked then.
+func loadEntryPoints(cfg *config.Config) ([]string, map[string]string, error) {
+	entryPoints := slices.Clone(cfg.EntryPoints)
+	addresses := make(map[string]string)
+	if cfg.GatewayConfig != "" {
+		data, err := os.ReadFile(cfg.GatewayConfig)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read gateway config: %w", err)
+		}
+		var file gatewayFile
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse gateway config %s: %w", cfg.GatewayConfig, err)
+		}
+		if len(file.Gateway.EntryPoints) == 0 {
+			return nil, nil, fmt.Errorf("gateway config %s declares no entry points", cfg.GatewayConfig)
+		}
+		for name, node := range file.Gateway.EntryPoints {
+			entryPoints = append(entryPoints, name)
+			var entryPoint gatewayEntryPoint
+			if err := node.Decode(&entryPoint); err == nil && entryPoint.Address != "" {
+				addresses[name] = entryPoint.Address
+			}
+		}
+	}
+	if len(entryPoints) == 0 {
+		return nil, nil, nil
+	}
+	slices.Sort(entryPoints)
+	return slices.Compact(entryPoints), addresses, nil
+}
+
+// knownEntryPoint reports whether a route may listen on the entry point.
+func (p *Provider) knownEntryPoint(name string) bool {
+	return p.entryPoints == nil || slices.Contains(p.entryPoints, name)
+}
+
+// addressEntryPoint returns the known entry point listening on a host:port
+// address, and reports whether a route may listen on the address: when
+// entry points are checked, only the addresses of known entry points are
+// allowed. An unspecified host, as in :5432, matches any host.
+func (p *Provider) addressEntryPoint(address string) (string, bool) {
+	if p.entryPoints == nil {
+		return "", true
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", false
+	}
+	for _, name := range p.entryPoints {
+		knownHost, knownPort, err := net.SplitHostPort(p.entryPointAddresses[name])
+		if err != nil || knownPort != port {
+			continue
+		}
+		if host == knownHost || unspecifiedHost(host) || unspecifiedHost(knownHost) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// unspecifiedHost reports whether a listen address host listens on every interface.
+func unspecifiedHost(host string) bool {
+	return host == "" || host == "0.0.0.0" || host == "::"
+}
+
+// parseEntryPoints parses the entry points of a route. It reports false,
+// rejecting the route, when an entry point is not known: dropping it would
+// expose the route on every entry point.
+func (p *Provider) parseEntryPoints(fc fieldContext, name, value string) ([]string, bool) {
+	entryPoints := parseList(value)
+	for _, entryPoint := range entryPoints {
+		if !entryPointNamePattern.MatchString(entryPoint) {
+			p.errorf(fc.source, fc.key("entrypoints"), "route %q rejected: invalid entry point name %q", name, entryPoint)
+			return nil, false
+		}
+		if !p.knownEntryPoint(entryPoint) {
+			p.errorf(fc.source, fc.key("entrypoints"), "route %q rejected: unknown entry point %q, expected one of %s",
+				name, entryPoint, strings.Join(p.entryPoints, ", "))
+			return nil, false
+		}
+	}
+	return entryPoints, true
+}
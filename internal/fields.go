@@ -0,0 +1,240 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jkaninda/logger"
+)
+
+// fieldKind is the type of the value of a route field.
+type fieldKind string
+
+const (
+	kindString   fieldKind = "string"
+	kindInteger  fieldKind = "integer"
+	kindBoolean  fieldKind = "boolean"
+	kindList     fieldKind = "list"
+	kindIntList  fieldKind = "integer list"
+	kindDuration fieldKind = "duration"
+)
+
+// Field value sources, from the highest precedence to the lowest.
+const (
+	sourceLabel   = "label"
+	sourceProject = "project"
+	sourceGlobal  = "global"
+	sourceBuiltin = "built-in"
+)
+
+// routeField describes a route field, as set by goma.{key} and
+// goma.routes.{routeName}.{key} labels.
+type routeField struct {
+	key  string
+	kind fieldKind
+	// builtin is the value used when the field is not set, if any
+	builtin string
+	// values lists the allowed values, any value is allowed when empty
+	values      []string
+	description string
+}
+
+// routeFieldRegistry lists the route fields understood by the parser.
+var routeFieldRegistry = []routeField{
+	{key: "name", kind: kindString, description: "Route name, defaults to the container or service name"},
+	{key: "path", kind: kindString, builtin: "/", description: "Public route path"},
+	{key: "port", kind: kindInteger, builtin: "80", description: "Backend port, defaults to the first published port of a Swarm service"},
+	{key: "scheme", kind: kindString, builtin: "http", values: []string{"http", "https", "h2c", "grpc", "grpcs", "ws", "wss"}, description: "Backend scheme"},
+	{key: "rewrite", kind: kindString, description: "Path the route path is rewritten to"},
+	{key: "priority", kind: kindInteger, builtin: "0", description: "Route priority"},
+	{key: "enabled", kind: kindBoolean, builtin: "true", description: "Enable or disable the route"},
+	{key: "hosts", kind: kindList, description: "Hosts matched by the route"},
+	{key: "methods", kind: kindList, description: "HTTP methods allowed by the route"},
+	{key: "entrypoints", kind: kindList, description: "Gateway entry points the route listens on, defaults to all"},
+	{key: "health_check.path", kind: kindString, description: "Backend health check path"},
+	{key: "health_check.interval", kind: kindDuration, builtin: "30s", description: "Health check interval"},
+	{key: "health_check.timeout", kind: kindDuration, builtin: "5s", description: "Health check timeout"},
+	{key: "health_check.healthy_statuses", kind: kindIntList, description: "HTTP statuses considered healthy"},
+	{key: "security.forward_host_headers", kind: kindBoolean, builtin: "true", description: "Forward the host headers to the backend"},
+	{key: "security.enable_exploit_protection", kind: kindBoolean, builtin: "false", description: "Block common exploit attempts"},
+	{key: "security.tls.insecure_skip_verify", kind: kindBoolean, builtin: "false", description: "Skip the verification of the backend TLS certificate"},
+	{key: "disable_metrics", kind: kindBoolean, builtin: "false", description: "Disable the route metrics"},
+	{key: "middlewares", kind: kindList, description: "Middlewares applied to the route"},
+	{key: "access.allow", kind: kindList, description: "Client CIDRs or IP addresses allowed, any other is denied"},
+	{key: "access.deny", kind: kindList, description: "Client CIDRs or IP addresses denied"},
+	{key: "auth.basic.users", kind: kindList, description: "Basic auth users, as user:bcrypt-hash"},
+	{key: "auth.basic.realm", kind: kindString, description: "Basic auth realm"},
+	{key: "auth.forward.container", kind: kindString, description: "Container or service of the forward auth service"},
+	{key: "auth.forward.url", kind: kindString, description: "URL of the forward auth service, instead of a container"},
+	{key: "auth.forward.port", kind: kindInteger, builtin: "80", description: "Port of the forward auth container"},
+	{key: "auth.forward.path", kind: kindString, builtin: "/", description: "Path of the forward auth endpoint on the container"},
+	{key: "auth.forward.response_headers", kind: kindList, description: "Headers copied from the forward auth response to the request"},
+	{key: "auth.jwt.jwks_url", kind: kindString, description: "URL of the JSON Web Key Set verifying the tokens"},
+	{key: "auth.jwt.issuer", kind: kindString, description: "Required token issuer"},
+	{key: "auth.jwt.audience", kind: kindString, description: "Required token audience"},
+	{key: "headers.request.set.*", kind: kindString, description: "Request header set before proxying, keyed by header name"},
+	{key: "headers.request.remove", kind: kindList, description: "Request headers removed before proxying"},
+	{key: "headers.response.set.*", kind: kindString, description: "Response header set, keyed by header name"},
+	{key: "headers.response.remove", kind: kindList, description: "Response headers removed"},
+	{key: "lb.algorithm", kind: kindString, values: []string{LBRoundRobin, LBLeastConnections, LBIPHash, LBWeighted}, description: "Load-balancing algorithm across the backends of the route"},
+	{key: "sticky.cookie_name", kind: kindString, description: "Cookie pinning a client to a backend"},
+	{key: "sticky.ttl", kind: kindDuration, description: "Lifetime of the sticky session cookie"},
+	{key: "canary.of", kind: kindString, description: "Name of the route this route is a canary of"},
+	{key: "canary.weight", kind: kindInteger, description: "Percentage of the traffic of the route sent to the canary, from 0 to 100"},
+	{key: "color", kind: kindString, values: []string{"blue", "green"}, description: "Blue/green color of the route"},
+}
+
+// providerLabels are the goma.* labels that are not route fields.
+var providerLabels = []routeField{
+	{key: "goma.enable", kind: kindBoolean, builtin: "false", description: "Enable route discovery for the container or service"},
+	{key: projectDefaultsLabel, kind: kindBoolean, builtin: "false", description: "Use the goma.* labels as the defaults of every route of the project"},
+	{key: controllerLabel, kind: kindBoolean, builtin: "false", description: "Read the goma.traffic.* labels of the container or service"},
+	{key: drainPeriodLabel, kind: kindDuration, description: "Time the routes are drained when the container stops"},
+}
+
+// trafficFields are the goma.traffic.{routeName}.* labels of controllers.
+var trafficFields = []routeField{
+	{key: "active", kind: kindString, values: []string{"blue", "green"}, description: "Active color of a blue/green route"},
+	{key: "canary_weight", kind: kindInteger, description: "Weight of the canaries of the route, from 0 to 100"},
+}
+
+// lookupProviderLabel returns the registry entry of a provider label.
+func lookupProviderLabel(key string) (routeField, bool) {
+	i := slices.IndexFunc(providerLabels, func(field routeField) bool { return field.key == key })
+	if i < 0 {
+		return routeField{}, false
+	}
+	return providerLabels[i], true
+}
+
+// lookupRouteField returns the registry entry of a route field. Keys ending
+// with * match any field starting with the same prefix.
+func lookupRouteField(key string) (routeField, bool) {
+	for _, field := range routeFieldRegistry {
+		if field.key == key {
+			return field, true
+		}
+		if prefix, wildcard := strings.CutSuffix(field.key, "*"); wildcard && strings.HasPrefix(key, prefix) && len(key) > len(prefix) {
+			return field, true
+		}
+	}
+	return routeField{}, false
+}
+
+// check reports whether value is valid for the field.
+func (f routeField) check(value string) error {
+	var err error
+	switch f.kind {
+	case kindInteger:
+		_, err = strconv.Atoi(value)
+	case kindBoolean:
+		_, err = strconv.ParseBool(value)
+	case kindDuration:
+		_, err = time.ParseDuration(value)
+	case kindIntList:
+		if len(parseIntList(value)) != len(parseList(value)) {
+			err = fmt.Errorf("invalid integer list")
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s %q", f.kind, value)
+	}
+	if len(f.values) > 0 && !slices.Contains(f.values, value) {
+		return fmt.Errorf("invalid value %q, expected one of %s", value, strings.Join(f.values, ", "))
+	}
+	return nil
+}
+
+// fieldDefault is a default route field value and where it was declared.
+type fieldDefault struct {
+	value  string
+	source string
+}
+
+// applyDefaults sets the route fields that are not set yet, recording the
+// source of each value.
+func applyDefaults(fields, sources map[string]string, defaults map[string]fieldDefault) {
+	for field, def := range defaults {
+		if _, exists := fields[field]; !exists {
+			fields[field] = def.value
+			sources[field] = def.source
+		}
+	}
+}
+
+// checkDefaults validates default labels, as set in the provider config.
+// Values holding references are only checked once resolved.
+func checkDefaults(name string, labels map[string]string) []error {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	var errs []error
+	for _, key := range keys {
+		field, ok := lookupRouteField(strings.TrimPrefix(key, "goma."))
+		switch {
+		case !ok:
+			errs = append(errs, fmt.Errorf("%s: unknown label %q", name, key))
+		case !inheritedLabel(key):
+			errs = append(errs, fmt.Errorf("%s: label %q cannot have a default", name, key))
+		case !referencePattern.MatchString(labels[key]):
+			if err := field.check(labels[key]); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %s: %w", name, key, err))
+			}
+		}
+	}
+	return errs
+}
+
+// logFieldSources logs where the field values of each route come from.
+func logFieldSources(routes []Route) {
+	for _, route := range routes {
+		logger.Debug("Route field sources", "route", route.Name, "source", route.Source, "fields", fieldSources(route.FieldSources))
+	}
+}
+
+// fieldSources describes where the value of each field of a route comes
+// from, including the built-in defaults of unset fields.
+func fieldSources(sources map[string]string) string {
+	parts := make([]string, 0, len(routeFieldRegistry))
+	for _, field := range routeFieldRegistry {
+		source, ok := sources[field.key]
+		if !ok {
+			if field.builtin == "" {
+				continue
+			}
+			source = sourceBuiltin
+		}
+		parts = append(parts, field.key+"="+source)
+	}
+	return strings.Join(parts, " ")
+}
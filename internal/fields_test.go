@@ -0,0 +1,233 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jkaninda/goma-docker-provider/internal/config"
+)
+
+// newDefaultsProvider returns a provider with route defaults for every route
+// and for the routes of the shop project.
+func newDefaultsProvider(global, project map[string]string) *Provider {
+	cfg := config.Default()
+	cfg.RouteDefaults = global
+	cfg.ProjectDefaults = map[string]map[string]string{"shop": project}
+	p := NewProvider(cfg)
+	p.setGlobalDefaults()
+	p.setProjectDefaults(nil)
+	return p
+}
+
+func TestDefaultsPrecedence(t *testing.T) {
+	p := newDefaultsProvider(
+		map[string]string{
+			"goma.port":     "8080",
+			"goma.priority": "1",
+			"goma.methods":  "GET",
+		},
+		map[string]string{
+			"goma.port":     "9090",
+			"goma.priority": "2",
+		},
+	)
+	parsed := parseTestContainer(p, "web", map[string]string{
+		"goma.enable":                "true",
+		"goma.port":                  "7070",
+		"com.docker.compose.project": "shop",
+	})
+	if len(parsed.Routes) != 1 {
+		t.Fatalf("routes = %v, diagnostics = %v", parsed.Routes, p.diagnostics)
+	}
+	route := parsed.Routes[0]
+	if route.Target != "http://web:7070" || route.Priority != 2 || !equalStrings(route.Methods, []string{"GET"}) {
+		t.Errorf("route = %+v, want the label port, project priority and global methods", route)
+	}
+
+	want := map[string]string{
+		"port":     sourceLabel,
+		"priority": sourceProject + " (config)",
+		"methods":  sourceGlobal,
+	}
+	for field, source := range want {
+		if got := route.FieldSources[field]; got != source {
+			t.Errorf("source of %s = %q, want %q", field, got, source)
+		}
+	}
+	described := fieldSources(route.FieldSources)
+	for _, part := range []string{"port=label", "priority=project (config)", "methods=global", "path=built-in", "scheme=built-in"} {
+		if !strings.Contains(described, part) {
+			t.Errorf("field sources %q do not contain %q", described, part)
+		}
+	}
+	if strings.Contains(described, "hosts=") {
+		t.Errorf("field sources %q list a field without a value", described)
+	}
+
+	// Routes of other projects only get the global defaults
+	other := parseTestContainer(p, "blog", map[string]string{"goma.enable": "true"}).Routes[0]
+	if other.Target != "http://blog:8080" || other.FieldSources["port"] != sourceGlobal {
+		t.Errorf("route = %+v, want the global port", other)
+	}
+}
+
+func TestDefaultsDoNotWarn(t *testing.T) {
+	defaults := map[string]string{
+		"goma.health_check.interval": "10s",
+		"goma.auth.basic.realm":      "Restricted",
+		"goma.sticky.ttl":            "1h",
+		"goma.canary.weight":         "10",
+	}
+	p := newDefaultsProvider(defaults, defaults)
+	parseTestContainer(p, "web", map[string]string{
+		"goma.enable":                "true",
+		"com.docker.compose.project": "shop",
+	})
+	if len(p.diagnostics) != 0 {
+		t.Errorf("diagnostics = %v, want none for defaults the route does not use", p.diagnostics)
+	}
+
+	// The same fields set by labels are reported
+	parseTestContainer(p, "api", map[string]string{
+		"goma.enable":                "true",
+		"goma.health_check.interval": "10s",
+		"goma.auth.basic.realm":      "Restricted",
+		"goma.sticky.ttl":            "1h",
+		"goma.canary.weight":         "10",
+	})
+	if len(p.diagnostics) != 4 {
+		t.Errorf("diagnostics = %v, want 4 warnings", p.diagnostics)
+	}
+}
+
+func TestDeclaredProjectDefaults(t *testing.T) {
+	p := newDefaultsProvider(nil, map[string]string{
+		"goma.port":        "9090",
+		"goma.middlewares": "rate-limit",
+	})
+	p.setProjectDefaults([]labeledSource{{
+		name: "shop-defaults",
+		labels: map[string]string{
+			projectDefaultsLabel:         "true",
+			"com.docker.compose.project": "shop",
+			"goma.hosts":                 "shop.example.com",
+			"goma.middlewares":           "auth",
+			"goma.name":                  "defaults",
+			"goma.canary.of":             "web",
+		},
+	}})
+	// Route names and canaries are not inherited
+	want := []string{
+		`goma.canary.of: not inherited by the routes of project "shop"`,
+		`goma.name: not inherited by the routes of project "shop"`,
+	}
+	if len(p.diagnostics) != len(want) {
+		t.Fatalf("diagnostics = %v, want %v", p.diagnostics, want)
+	}
+	for i, message := range want {
+		if got := p.diagnostics[i].Label + ": " + p.diagnostics[i].Message; got != message {
+			t.Errorf("diagnostic %d = %q, want %q", i, got, message)
+		}
+	}
+
+	p.diagnostics = nil
+	parsed := parseTestContainer(p, "web", map[string]string{
+		"goma.enable":                "true",
+		"goma.hosts":                 "www.example.com",
+		"com.docker.compose.project": "shop",
+	})
+	if len(parsed.Routes) != 1 || len(p.diagnostics) != 0 {
+		t.Fatalf("routes = %v, diagnostics = %v", parsed.Routes, p.diagnostics)
+	}
+	route := parsed.Routes[0]
+	// Labels override the declared defaults, which override the configuration
+	if !equalStrings(route.Hosts, []string{"www.example.com"}) || route.Target != "http://web:9090" ||
+		!equalStrings(route.Middlewares, []string{"auth"}) {
+		t.Errorf("route = %+v", route)
+	}
+	sources := map[string]string{
+		"hosts":       sourceLabel,
+		"port":        sourceProject + " (config)",
+		"middlewares": sourceProject + " (shop-defaults)",
+	}
+	for field, source := range sources {
+		if got := route.FieldSources[field]; got != source {
+			t.Errorf("source of %s = %q, want %q", field, got, source)
+		}
+	}
+}
+
+func TestDeclaredProjectDefaultsWithoutProject(t *testing.T) {
+	p := newDefaultsProvider(nil, nil)
+	p.setProjectDefaults([]labeledSource{{
+		name:   "defaults",
+		labels: map[string]string{projectDefaultsLabel: "true", "goma.hosts": "example.com"},
+	}})
+	if len(p.diagnostics) != 1 || !strings.Contains(p.diagnostics[0].Message, "not part of a compose project") {
+		t.Errorf("diagnostics = %v", p.diagnostics)
+	}
+}
+
+func TestRouteNameGroupByProject(t *testing.T) {
+	tests := []struct {
+		name, project, want string
+		group               bool
+	}{
+		{"web", "shop", "web", false},
+		{"web", "shop", "shop-web", true},
+		{"shop-web-1", "shop", "shop-web-1", true},
+		{"shop_web_1", "shop", "shop_web_1", true},
+		{"shopping", "shop", "shop-shopping", true},
+		{"web", "", "web", true},
+	}
+	for _, tt := range tests {
+		cfg := config.Default()
+		cfg.GroupByProject = tt.group
+		p := NewProvider(cfg)
+		if got := p.routeName(tt.name, tt.project); got != tt.want {
+			t.Errorf("routeName(%q, %q) with grouping %v = %q, want %q", tt.name, tt.project, tt.group, got, tt.want)
+		}
+	}
+
+	// Two projects with a web service do not collide
+	cfg := config.Default()
+	cfg.GroupByProject = true
+	p := NewProvider(cfg)
+	var names []string
+	for _, project := range []string{"blog", "shop"} {
+		parsed := parseTestContainer(p, "web", map[string]string{
+			"goma.enable":                "true",
+			"com.docker.compose.project": project,
+		})
+		for _, route := range parsed.Routes {
+			names = append(names, route.Name)
+		}
+	}
+	if !equalStrings(names, []string{"blog-web", "shop-web"}) {
+		t.Errorf("route names = %v", names)
+	}
+}
@@ -0,0 +1,101 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"net/textproto"
+	"sort"
+	"strings"
+)
+
+// headerMiddleware builds the private headers middleware of the
+// goma.headers.* fields of a route. It reports false when a header name or
+// value is invalid, the route is rejected then.
+func (p *Provider) headerMiddleware(fc fieldContext, name string, fields map[string]string) ([]Middleware, bool) {
+	request, ok := p.parseHeaders(fc, name, "request", fields)
+	if !ok {
+		return nil, false
+	}
+	response, ok := p.parseHeaders(fc, name, "response", fields)
+	if !ok {
+		return nil, false
+	}
+	if request.empty() && response.empty() {
+		return nil, true
+	}
+	return []Middleware{privateMiddleware(name, "headers", MiddlewareHeaders, HeadersRule{
+		Request:  request,
+		Response: response,
+	})}, true
+}
+
+// parseHeaders parses the headers.<direction>.set.<Name> and
+// headers.<direction>.remove fields, canonicalizing the header names.
+func (p *Provider) parseHeaders(fc fieldContext, name, direction string, fields map[string]string) (HeaderRule, bool) {
+	var rule HeaderRule
+	setPrefix := "headers." + direction + ".set."
+	keys := make([]string, 0)
+	for field := range fields {
+		if strings.HasPrefix(field, setPrefix) {
+			keys = append(keys, field)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, field := range keys {
+		header := strings.TrimPrefix(field, setPrefix)
+		if !validToken(header) {
+			p.errorf(fc.source, fc.key(field), "route %q rejected: invalid header name %q", name, header)
+			return HeaderRule{}, false
+		}
+		value := fields[field]
+		if strings.ContainsAny(value, "\r\n\x00") {
+			p.errorf(fc.source, fc.key(field), "route %q rejected: header value contains a control character", name)
+			return HeaderRule{}, false
+		}
+		header = textproto.CanonicalMIMEHeaderKey(header)
+		if rule.Set == nil {
+			rule.Set = make(map[string]string)
+		}
+		if _, exists := rule.Set[header]; exists {
+			p.warnf(fc.source, fc.key(field), "header %s is set more than once on route %q, this value is used", header, name)
+		}
+		rule.Set[header] = value
+	}
+
+	removeField := "headers." + direction + ".remove"
+	for _, header := range parseList(fields[removeField]) {
+		if !validToken(header) {
+			p.errorf(fc.source, fc.key(removeField), "route %q rejected: invalid header name %q", name, header)
+			return HeaderRule{}, false
+		}
+		header = textproto.CanonicalMIMEHeaderKey(header)
+		if _, set := rule.Set[header]; set {
+			p.warnf(fc.source, fc.key(removeField), "header %s is both set and removed on route %q", header, name)
+		}
+		rule.Remove = append(rule.Remove, header)
+	}
+	return rule, true
+}
@@ -43,24 +43,6 @@ func getRouteLabel(labels map[string]string, key, defaultValue string) string {
 	return defaultValue
 }
 
-func parseBool(labels map[string]string, key string, defaultValue bool) bool {
-	if value, exists := labels[key]; exists {
-		if parsed, err := strconv.ParseBool(value); err == nil {
-			return parsed
-		}
-	}
-	return defaultValue
-}
-
-func parseBoolFromMap(labels map[string]string, key string, defaultValue bool) bool {
-	if value, exists := labels[key]; exists {
-		if parsed, err := strconv.ParseBool(value); err == nil {
-			return parsed
-		}
-	}
-	return defaultValue
-}
-
 func parseList(value string) []string {
 	items := strings.Split(value, ",")
 	result := make([]string, 0, len(items))
@@ -84,3 +66,16 @@ func parseIntList(value string) []int {
 	}
 	return result
 }
+
+// validToken reports whether name is an RFC 7230 token, as cookie and header names are.
+func validToken(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r <= ' ' || r >= 0x7f || strings.ContainsRune("()<>@,;:\\\"/[]?={}", r) {
+			return false
+		}
+	}
+	return true
+}
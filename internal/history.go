@@ -0,0 +1,239 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jkaninda/logger"
+)
+
+const (
+	snapshotExt        = ".snapshot"
+	snapshotTimeFormat = "20060102T150405.000Z"
+	pinFile            = "pinned"
+)
+
+// snapshotIDPattern matches {timestamp}-{hash prefix}
+var snapshotIDPattern = regexp.MustCompile(`^\d{8}T\d{6}\.\d{3}Z-[0-9a-f]{12}$`)
+
+// Snapshot is a generated configuration kept in the history.
+type Snapshot struct {
+	ID      string    `json:"id"`
+	Created time.Time `json:"created"`
+	Hash    string    `json:"hash"`
+	Routes  int       `json:"routes"`
+	Pinned  bool      `json:"pinned"`
+}
+
+// History keeps the last generated configurations on disk.
+//
+// Snapshots are stored with a .snapshot extension so the gateway never loads
+// them, even when the history directory lives inside the output directory.
+type History struct {
+	dir   string
+	limit int
+}
+
+func newHistory(dir string, limit int) *History {
+	return &History{dir: dir, limit: limit}
+}
+
+// List returns the snapshots, the most recent first.
+func (h *History) List() ([]Snapshot, error) {
+	entries, err := os.ReadDir(h.dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	pinned, err := h.Pinned()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make([]Snapshot, 0, len(entries))
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), snapshotExt)
+		if !ok || !snapshotIDPattern.MatchString(id) {
+			continue
+		}
+		timestamp, hash, _ := strings.Cut(id, "-")
+		created, _ := time.Parse(snapshotTimeFormat, timestamp)
+		snapshot := Snapshot{ID: id, Created: created, Hash: hash, Pinned: id == pinned}
+		if data, err := h.Read(id); err == nil {
+			snapshot.Routes = len(parseRoutes(data))
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].ID > snapshots[j].ID
+	})
+	return snapshots, nil
+}
+
+// Read returns the content of a snapshot.
+func (h *History) Read(id string) ([]byte, error) {
+	if !snapshotIDPattern.MatchString(id) {
+		return nil, fmt.Errorf("invalid snapshot id %q", id)
+	}
+	data, err := os.ReadFile(filepath.Join(h.dir, id+snapshotExt))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("snapshot %s not found", id)
+	}
+	return data, err
+}
+
+// Pinned returns the id of the pinned snapshot, or an empty string.
+func (h *History) Pinned() (string, error) {
+	data, err := os.ReadFile(filepath.Join(h.dir, pinFile))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Pin pins a snapshot, the provider keeps writing it until it is unpinned.
+func (h *History) Pin(id string) error {
+	if _, err := h.Read(id); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(h.dir, pinFile), []byte(id+"\n"), 0644)
+}
+
+// Unpin removes the pin, the provider resumes generating the configuration.
+func (h *History) Unpin() error {
+	err := os.Remove(filepath.Join(h.dir, pinFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// save stores a generated configuration and prunes the oldest snapshots.
+func (h *History) save(data []byte) error {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])[:12]
+
+	snapshots, err := h.List()
+	if err != nil {
+		return err
+	}
+	if len(snapshots) > 0 && snapshots[0].Hash == hash {
+		return nil
+	}
+
+	if err := os.MkdirAll(h.dir, 0755); err != nil {
+		return err
+	}
+	id := fmt.Sprintf("%s-%s", time.Now().UTC().Format(snapshotTimeFormat), hash)
+	if err := os.WriteFile(filepath.Join(h.dir, id+snapshotExt), data, 0644); err != nil {
+		return err
+	}
+	logger.Debug("Configuration snapshot saved", "id", id)
+
+	// Prune, the new snapshot is not listed yet
+	kept := 1
+	for _, snapshot := range snapshots {
+		if kept < h.limit || snapshot.Pinned {
+			kept++
+			continue
+		}
+		if err := os.Remove(filepath.Join(h.dir, snapshot.ID+snapshotExt)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// History returns the configuration history, or nil when it is disabled.
+func (p *Provider) History() *History {
+	if p.config.HistoryLimit == 0 {
+		return nil
+	}
+	return newHistory(p.config.HistoryDir, p.config.HistoryLimit)
+}
+
+// Rollback restores a snapshot as the generated configuration and pins it.
+func (p *Provider) Rollback(id string) error {
+	history := p.History()
+	if history == nil {
+		return errors.New("history is disabled")
+	}
+	data, err := history.Read(id)
+	if err != nil {
+		return err
+	}
+	if err := history.Pin(id); err != nil {
+		return err
+	}
+	return os.WriteFile(p.OutputFile(), data, 0644)
+}
+
+// syncPinned keeps the pinned snapshot in place of the generated configuration.
+// It reports whether a snapshot is pinned.
+func (p *Provider) syncPinned() (bool, error) {
+	history := p.History()
+	if history == nil {
+		return false, nil
+	}
+	id, err := history.Pinned()
+	if err != nil || id == "" {
+		return false, err
+	}
+	data, err := history.Read(id)
+	if err != nil {
+		return true, err
+	}
+
+	// Regenerate as soon as the snapshot is unpinned
+	p.lastHash = ""
+	p.lastRoutes = nil
+	p.lastSecrets = nil
+
+	if current, err := os.ReadFile(p.OutputFile()); err == nil && bytes.Equal(current, data) {
+		return true, nil
+	}
+	if err := os.WriteFile(p.OutputFile(), data, 0644); err != nil {
+		return true, err
+	}
+	logger.Warn("Configuration pinned to a history snapshot, generated routes are ignored until unpinned", "id", id)
+	return true, nil
+}
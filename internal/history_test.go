@@ -0,0 +1,203 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jkaninda/goma-docker-provider/internal/config"
+)
+
+// writeSnapshot stores a snapshot as History.save would.
+func writeSnapshot(t *testing.T, dir, id string, data []byte) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, id+snapshotExt), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func snapshotIDs(t *testing.T, h *History) []string {
+	t.Helper()
+	snapshots, err := h.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		ids = append(ids, snapshot.ID)
+	}
+	return ids
+}
+
+func TestHistoryList(t *testing.T) {
+	dir := t.TempDir()
+	h := newHistory(dir, 10)
+	if ids := snapshotIDs(t, h); len(ids) != 0 {
+		t.Fatalf("empty history = %v", ids)
+	}
+
+	data, err := MarshalConfiguration(goldenConfig(), FormatYAML, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeSnapshot(t, dir, "20260101T000000.000Z-aaaaaaaaaaaa", data)
+	writeSnapshot(t, dir, "20260102T000000.000Z-bbbbbbbbbbbb", []byte("routes: []\n"))
+	writeSnapshot(t, dir, "not-a-snapshot", nil)
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshots, err := h.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 || snapshots[0].Hash != "bbbbbbbbbbbb" || snapshots[1].Hash != "aaaaaaaaaaaa" {
+		t.Fatalf("snapshots = %+v, want the two snapshots, most recent first", snapshots)
+	}
+	if want := len(goldenConfig().Routes); snapshots[1].Routes != want {
+		t.Errorf("routes = %d, want %d", snapshots[1].Routes, want)
+	}
+	if snapshots[1].Created.IsZero() {
+		t.Error("creation time not parsed")
+	}
+
+	if _, err := h.Read("../outside"); err == nil {
+		t.Error("Read accepted an invalid id")
+	}
+	if _, err := h.Read("20260103T000000.000Z-cccccccccccc"); err == nil {
+		t.Error("Read accepted a missing snapshot")
+	}
+}
+
+func TestHistorySavePrune(t *testing.T) {
+	dir := t.TempDir()
+	h := newHistory(dir, 2)
+	oldest := "20260101T000000.000Z-aaaaaaaaaaaa"
+	middle := "20260102T000000.000Z-bbbbbbbbbbbb"
+	newest := "20260103T000000.000Z-cccccccccccc"
+	for _, id := range []string{oldest, middle, newest} {
+		writeSnapshot(t, dir, id, []byte(id))
+	}
+	if err := h.Pin(oldest); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.save([]byte("routes: []\n")); err != nil {
+		t.Fatal(err)
+	}
+	ids := snapshotIDs(t, h)
+	// The new snapshot and the most recent one are kept, with the pinned one
+	if len(ids) != 3 || ids[1] != newest || ids[2] != oldest {
+		t.Fatalf("snapshots = %v, want the new one, %s and %s", ids, newest, oldest)
+	}
+
+	// Saving the same configuration again does not add a snapshot
+	if err := h.save([]byte("routes: []\n")); err != nil {
+		t.Fatal(err)
+	}
+	if again := snapshotIDs(t, h); !equalStrings(again, ids) {
+		t.Errorf("snapshots = %v, want %v", again, ids)
+	}
+}
+
+func TestHistoryPin(t *testing.T) {
+	dir := t.TempDir()
+	h := newHistory(dir, 10)
+	id := "20260101T000000.000Z-aaaaaaaaaaaa"
+	writeSnapshot(t, dir, id, []byte("routes: []\n"))
+
+	if err := h.Pin("20260102T000000.000Z-bbbbbbbbbbbb"); err == nil {
+		t.Error("Pin accepted a missing snapshot")
+	}
+	if err := h.Pin(id); err != nil {
+		t.Fatal(err)
+	}
+	if pinned, _ := h.Pinned(); pinned != id {
+		t.Errorf("pinned = %q, want %q", pinned, id)
+	}
+	if snapshots, _ := h.List(); len(snapshots) != 1 || !snapshots[0].Pinned {
+		t.Errorf("snapshots = %+v, want the snapshot pinned", snapshots)
+	}
+
+	if err := h.Unpin(); err != nil {
+		t.Fatal(err)
+	}
+	if pinned, _ := h.Pinned(); pinned != "" {
+		t.Errorf("pinned = %q after unpin", pinned)
+	}
+	if err := h.Unpin(); err != nil {
+		t.Errorf("second unpin: %v", err)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	cfg := config.Default()
+	cfg.OutputDir = t.TempDir()
+	cfg.HistoryDir = filepath.Join(cfg.OutputDir, ".history")
+	p := NewProvider(cfg)
+
+	id := "20260101T000000.000Z-aaaaaaaaaaaa"
+	snapshot := []byte("routes: []\n")
+	writeSnapshot(t, cfg.HistoryDir, id, snapshot)
+	if err := p.Rollback(id); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(p.OutputFile()); string(data) != string(snapshot) {
+		t.Errorf("output = %q, want the snapshot", data)
+	}
+
+	// The pinned snapshot replaces a configuration written in the meantime
+	if err := os.WriteFile(p.OutputFile(), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p.lastHash = "hash"
+	pinned, err := p.syncPinned()
+	if err != nil || !pinned {
+		t.Fatalf("syncPinned() = %v, %v", pinned, err)
+	}
+	if data, _ := os.ReadFile(p.OutputFile()); string(data) != string(snapshot) {
+		t.Errorf("output = %q, want the pinned snapshot", data)
+	}
+	if p.lastHash != "" {
+		t.Error("last hash kept while pinned, unpinning would not regenerate")
+	}
+
+	if err := p.History().Unpin(); err != nil {
+		t.Fatal(err)
+	}
+	if pinned, err := p.syncPinned(); err != nil || pinned {
+		t.Errorf("syncPinned() after unpin = %v, %v", pinned, err)
+	}
+
+	cfg.HistoryLimit = 0
+	if err := NewProvider(cfg).Rollback(id); err == nil {
+		t.Error("Rollback succeeded with the history disabled")
+	}
+}
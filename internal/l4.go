@@ -0,0 +1,291 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Layer-4 protocols
+const (
+	ProtocolTCP = "tcp"
+	ProtocolUDP = "udp"
+)
+
+var (
+	// l4Pattern matches goma.tcp.<field> and goma.tcp.<name>.<field> labels, and their udp counterparts.
+	l4Pattern = regexp.MustCompile(`^goma\.(tcp|udp)\.(?:([^.]+)\.)?([^.]+)$`)
+	// entryPointNamePattern matches a named gateway entry point.
+	entryPointNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+)
+
+// l4Fields are the fields of the layer-4 labels of each protocol.
+var l4Fields = map[string][]routeField{
+	ProtocolTCP: {
+		{key: "name", kind: kindString, description: "Route name, defaults to the container or service name followed by -tcp"},
+		{key: "entrypoint", kind: kindString, description: "Entry point name, or host:port listen address"},
+		{key: "port", kind: kindInteger, description: "Backend port"},
+		{key: "tls_passthrough", kind: kindBoolean, builtin: "false", description: "Forward TLS connections without terminating them"},
+		{key: "sni", kind: kindList, description: "Server names matched with TLS passthrough"},
+	},
+	ProtocolUDP: {
+		{key: "name", kind: kindString, description: "Route name, defaults to the container or service name followed by -udp"},
+		{key: "entrypoint", kind: kindString, description: "Entry point name, or host:port listen address"},
+		{key: "port", kind: kindInteger, description: "Backend port"},
+	},
+}
+
+// validL4Label reports whether key is a known goma.tcp.* or goma.udp.* label.
+func validL4Label(key string) bool {
+	matches := l4Pattern.FindStringSubmatch(key)
+	return matches != nil && slices.ContainsFunc(l4Fields[matches[1]], func(field routeField) bool {
+		return field.key == matches[3]
+	})
+}
+
+// hasL4Labels reports whether the labels declare layer-4 routes.
+func hasL4Labels(labels map[string]string) bool {
+	for key := range labels {
+		if l4Pattern.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasRouteLabels reports whether the labels set HTTP route fields.
+func hasRouteLabels(labels map[string]string) bool {
+	for key := range labels {
+		if namedRoutePattern.MatchString(key) {
+			return true
+		}
+		if _, ok := lookupRouteField(strings.TrimPrefix(key, "goma.")); ok && strings.HasPrefix(key, "goma.") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseL4Routes builds the layer-4 routes declared by the labels of a container or service.
+func (p *Provider) parseL4Routes(src routeSource, labels map[string]string) []L4Route {
+	type l4Key struct{ protocol, name string }
+	declared := make(map[l4Key]bool)
+	for key := range labels {
+		if matches := l4Pattern.FindStringSubmatch(key); matches != nil {
+			declared[l4Key{matches[1], matches[2]}] = true
+		}
+	}
+	keys := make([]l4Key, 0, len(declared))
+	for key := range declared {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].protocol != keys[j].protocol {
+			return keys[i].protocol < keys[j].protocol
+		}
+		return keys[i].name < keys[j].name
+	})
+
+	routes := make([]L4Route, 0, len(keys))
+	for _, key := range keys {
+		prefix := fmt.Sprintf("goma.%s.", key.protocol)
+		defaultName := fmt.Sprintf("%s-%s", src.name, key.protocol)
+		if key.name != "" {
+			prefix += key.name + "."
+			defaultName = fmt.Sprintf("%s-%s", src.name, key.name)
+		}
+		if route, ok := p.parseL4Route(src, key.protocol, prefix, defaultName, labels); ok {
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+// parseL4Route builds a layer-4 route from the fields under prefix. It
+// reports false when a required field is missing or invalid.
+func (p *Provider) parseL4Route(src routeSource, protocol, prefix, defaultName string, labels map[string]string) (L4Route, bool) {
+	fields := routeFields(labels, prefix)
+	for field := range fields {
+		if strings.Contains(field, ".") {
+			// Field of a named route
+			delete(fields, field)
+		}
+	}
+	fc := fieldContext{source: src.name, prefix: prefix}
+	name := p.routeName(getRouteLabel(fields, "name", defaultName), src.owner.projectName())
+
+	entryPoint := fields["entrypoint"]
+	switch {
+	case entryPoint == "":
+		p.errorf(fc.source, fc.key("entrypoint"), "%s route %q rejected: entrypoint is required", protocol, name)
+		return L4Route{}, false
+	case !validEntryPoint(entryPoint):
+		p.errorf(fc.source, fc.key("entrypoint"), "%s route %q rejected: invalid entrypoint %q, expected a name or a host:port address", protocol, name, entryPoint)
+		return L4Route{}, false
+	case entryPointNamePattern.MatchString(entryPoint) && !p.knownEntryPoint(entryPoint):
+		p.errorf(fc.source, fc.key("entrypoint"), "%s route %q rejected: unknown entry point %q, expected one of %s",
+			protocol, name, entryPoint, strings.Join(p.entryPoints, ", "))
+		return L4Route{}, false
+	case !entryPointNamePattern.MatchString(entryPoint):
+		known, ok := p.addressEntryPoint(entryPoint)
+		if !ok {
+			p.errorf(fc.source, fc.key("entrypoint"), "%s route %q rejected: listen address %q is not the address of a known entry point, expected one of %s",
+				protocol, name, entryPoint, strings.Join(p.entryPoints, ", "))
+			return L4Route{}, false
+		}
+		if known != "" {
+			p.infof(fc.source, fc.key("entrypoint"), "listen address %q of %s route %q is the address of entry point %q", entryPoint, protocol, name, known)
+		}
+	}
+
+	port := fields["port"]
+	if port == "" {
+		p.errorf(fc.source, fc.key("port"), "%s route %q rejected: port is required", protocol, name)
+		return L4Route{}, false
+	}
+	if !validPort(port) {
+		p.errorf(fc.source, fc.key("port"), "%s route %q rejected: invalid port %q", protocol, name, port)
+		return L4Route{}, false
+	}
+
+	route := L4Route{
+		Name:       name,
+		Protocol:   protocol,
+		EntryPoint: entryPoint,
+		Target:     net.JoinHostPort(src.host, port),
+		Source:     src.name,
+	}
+	if protocol == ProtocolTCP {
+		route.TLSPassthrough = p.parseBoolField(fc, fields, "tls_passthrough", false)
+		if sni := fields["sni"]; sni != "" {
+			if !route.TLSPassthrough {
+				p.warnf(fc.source, fc.key("sni"), "ignored on route %q, it requires tls_passthrough", name)
+			} else {
+				route.Hosts = parseList(sni)
+			}
+		}
+	}
+	return route, true
+}
+
+// validEntryPoint reports whether value is an entry point name or a host:port listen address.
+func validEntryPoint(value string) bool {
+	if entryPointNamePattern.MatchString(value) {
+		return true
+	}
+	_, port, err := net.SplitHostPort(value)
+	return err == nil && validPort(port)
+}
+
+func validPort(value string) bool {
+	port, err := strconv.Atoi(value)
+	return err == nil && port > 0 && port <= 65535
+}
+
+// enforceL4HostPolicy drops the layer-4 routes matching server names their
+// source is not allowed to use.
+func (p *Provider) enforceL4HostPolicy(source string, owner routeOwner, routes []L4Route) []L4Route {
+	if p.policy == nil {
+		return routes
+	}
+	allowed := make([]L4Route, 0, len(routes))
+	for _, route := range routes {
+		rejected := ""
+		for _, host := range route.Hosts {
+			if !p.policy.Hosts.allowHost(host, owner) {
+				rejected = host
+				break
+			}
+		}
+		if rejected != "" {
+			p.errorf(source, "", "%s route %q rejected by host policy: host %q is not allowed for %s", route.Protocol, route.Name, rejected, ownerDescription(owner))
+			p.metrics.hostPolicyRejections.add(source, rejected)
+			continue
+		}
+		allowed = append(allowed, route)
+	}
+	return allowed
+}
+
+// finalizeL4Routes resolves the layer-4 routes competing for the same
+// entry point and sorts them.
+func (p *Provider) finalizeL4Routes(routes []L4Route) []L4Route {
+	if len(routes) == 0 {
+		return nil
+	}
+	// Compare routes from the oldest source to the newest
+	sort.SliceStable(routes, func(i, j int) bool {
+		if !routes[i].Created.Equal(routes[j].Created) {
+			return routes[i].Created.Before(routes[j].Created)
+		}
+		return routes[i].Name < routes[j].Name
+	})
+
+	rejected := make(map[int]bool)
+	for i := range routes {
+		for j := i + 1; j < len(routes); j++ {
+			if rejected[i] || rejected[j] || !l4Conflict(routes[i], routes[j]) {
+				continue
+			}
+			older, newer := routes[i], routes[j]
+			conflict := fmt.Sprintf("%s route %q (%s) conflicts with %s route %q (%s) on entrypoint %s",
+				newer.Protocol, newer.Name, newer.Source, older.Protocol, older.Name, older.Source, newer.EntryPoint)
+			if p.config.ConflictPolicy == ConflictWarn {
+				p.warnf(newer.Source, "", "%s", conflict)
+				continue
+			}
+			// A listener forwards to a single backend, priorities cannot break the tie
+			rejected[j] = true
+			p.errorf(newer.Source, "", "%s: route %q rejected", conflict, newer.Name)
+		}
+	}
+
+	kept := make([]L4Route, 0, len(routes))
+	for i := range routes {
+		if !rejected[i] {
+			kept = append(kept, routes[i])
+		}
+	}
+	sort.Slice(kept, func(i, j int) bool {
+		return kept[i].Name < kept[j].Name
+	})
+	return kept
+}
+
+// l4Conflict reports whether two layer-4 routes claim the same traffic. TCP
+// routes using TLS passthrough share an entry point when their server names differ.
+func l4Conflict(a, b L4Route) bool {
+	if a.Protocol != b.Protocol || a.EntryPoint != b.EntryPoint {
+		return false
+	}
+	_, overlap := overlappingHost(a.Hosts, b.Hosts)
+	return overlap
+}
@@ -0,0 +1,162 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jkaninda/goma-docker-provider/internal/config"
+)
+
+const testGatewayConfig = `gateway:
+  entryPoints:
+    web:
+      address: ":80"
+    postgres:
+      address: "0.0.0.0:5432"
+    dns:
+      address: "10.0.0.1:53"
+`
+
+func newEntryPointsProvider(t *testing.T, gatewayConfig string) *Provider {
+	t.Helper()
+	cfg := config.Default()
+	if gatewayConfig != "" {
+		cfg.GatewayConfig = filepath.Join(t.TempDir(), "goma.yml")
+		if err := os.WriteFile(cfg.GatewayConfig, []byte(gatewayConfig), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p := NewProvider(cfg)
+	var err error
+	if p.entryPoints, p.entryPointAddresses, err = loadEntryPoints(cfg); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestLoadEntryPoints(t *testing.T) {
+	p := newEntryPointsProvider(t, testGatewayConfig)
+	if got := strings.Join(p.entryPoints, ","); got != "dns,postgres,web" {
+		t.Errorf("entry points = %s", got)
+	}
+	if p.entryPointAddresses["postgres"] != "0.0.0.0:5432" {
+		t.Errorf("addresses = %v", p.entryPointAddresses)
+	}
+	if p := newEntryPointsProvider(t, ""); p.entryPoints != nil {
+		t.Errorf("entry points = %v, want nil when none are configured", p.entryPoints)
+	}
+}
+
+func TestParseL4RouteEntryPoint(t *testing.T) {
+	tests := []struct {
+		name       string
+		gateway    string
+		protocol   string
+		entryPoint string
+		ok         bool
+		severity   Severity
+	}{
+		{"known name", testGatewayConfig, ProtocolTCP, "postgres", true, ""},
+		{"unknown name", testGatewayConfig, ProtocolTCP, "mysql", false, SeverityError},
+		{"address of a known entry point", testGatewayConfig, ProtocolTCP, ":5432", true, SeverityInfo},
+		{"specific host of a wildcard entry point", testGatewayConfig, ProtocolTCP, "127.0.0.1:5432", true, SeverityInfo},
+		{"same host", testGatewayConfig, ProtocolUDP, "10.0.0.1:53", true, SeverityInfo},
+		{"other host", testGatewayConfig, ProtocolUDP, "10.0.0.2:53", false, SeverityError},
+		{"unknown port", testGatewayConfig, ProtocolTCP, ":6379", false, SeverityError},
+		{"entry points not checked", "", ProtocolTCP, ":6379", true, ""},
+		{"invalid address", "", ProtocolTCP, "db:port", false, SeverityError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newEntryPointsProvider(t, tt.gateway)
+			prefix := "goma." + tt.protocol + "."
+			_, ok := p.parseL4Route(routeSource{name: "db", host: "db"}, tt.protocol, prefix, "db-"+tt.protocol, map[string]string{
+				prefix + "entrypoint": tt.entryPoint,
+				prefix + "port":       "5432",
+			})
+			if ok != tt.ok {
+				t.Fatalf("parseL4Route() = %v, want %v (diagnostics: %v)", ok, tt.ok, p.diagnostics)
+			}
+			switch {
+			case tt.severity == "" && len(p.diagnostics) > 0:
+				t.Errorf("unexpected diagnostics: %v", p.diagnostics)
+			case tt.severity != "" && (len(p.diagnostics) != 1 || p.diagnostics[0].Severity != tt.severity):
+				t.Errorf("diagnostics = %v, want one %s", p.diagnostics, tt.severity)
+			}
+		})
+	}
+}
+
+func TestParseRouteEntryPoints(t *testing.T) {
+	tests := []struct {
+		name    string
+		gateway string
+		labels  map[string]string
+		want    []string
+		ok      bool
+	}{
+		{"known entry points", testGatewayConfig, map[string]string{"goma.entrypoints": "web, dns"}, []string{"web", "dns"}, true},
+		{"no entry points listens on all", testGatewayConfig, map[string]string{}, nil, true},
+		{"unknown entry point", testGatewayConfig, map[string]string{"goma.entrypoints": "web,websecure"}, nil, false},
+		{"invalid name", testGatewayConfig, map[string]string{"goma.entrypoints": "web:80"}, nil, false},
+		{"any name without known entry points", "", map[string]string{"goma.entrypoints": "websecure"}, []string{"websecure"}, true},
+		{"inherited by named routes", testGatewayConfig, map[string]string{"goma.entrypoints": "web", "goma.routes.api.path": "/api"}, []string{"web"}, true},
+		{"overridden by named routes", testGatewayConfig, map[string]string{"goma.entrypoints": "web", "goma.routes.api.entrypoints": "dns"}, []string{"dns"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newEntryPointsProvider(t, tt.gateway)
+			tt.labels["goma.enable"] = "true"
+			parsed := parseTestContainer(p, "web", tt.labels)
+			if !tt.ok {
+				if len(parsed.Routes) != 0 || !hasErrors(p.diagnostics) {
+					t.Errorf("routes = %v, diagnostics = %v, want the route rejected", parsed.Routes, p.diagnostics)
+				}
+				return
+			}
+			if len(parsed.Routes) != 1 || hasErrors(p.diagnostics) {
+				t.Fatalf("routes = %v, diagnostics = %v", parsed.Routes, p.diagnostics)
+			}
+			if got := parsed.Routes[0].EntryPoints; !equalStrings(got, tt.want) {
+				t.Errorf("entry points = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouteEntryPointsOutput(t *testing.T) {
+	routes := []Route{{Name: "admin", Path: "/", Target: "http://admin:80", EntryPoints: []string{"internal"}}, {Name: "web", Path: "/", Target: "http://web:80"}}
+	data, err := MarshalConfiguration(GomaConfig{Routes: routes}, FormatYAML, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(data), "entryPoints:") != 1 || !strings.Contains(string(data), "- internal") {
+		t.Errorf("output does not restrict only the admin route:\n%s", data)
+	}
+}
@@ -0,0 +1,331 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/jkaninda/logger"
+)
+
+const (
+	leaseFile = ".goma-docker-provider.lock"
+	// leaseGuardFile is created exclusively by the replica reading and
+	// writing the lease, so that two replicas never update it at once
+	leaseGuardFile = leaseFile + ".guard"
+)
+
+// errLeaseBusy is returned when another replica holds the lease guard.
+var errLeaseBusy = errors.New("leader lease is being updated by another replica")
+
+// Replica roles
+const (
+	RoleSingle  = "single"
+	RoleLeader  = "leader"
+	RoleStandby = "standby"
+)
+
+// leaseRecord is the content of the lock file shared by the replicas.
+type leaseRecord struct {
+	Holder        string    `json:"holder"`
+	AcquiredAt    time.Time `json:"acquiredAt"`
+	RenewedAt     time.Time `json:"renewedAt"`
+	LeaseDuration string    `json:"leaseDuration"`
+}
+
+func (r leaseRecord) equal(other leaseRecord) bool {
+	return r.Holder == other.Holder && r.RenewedAt.Equal(other.RenewedAt)
+}
+
+// leaderElector elects a leader between replicas sharing the output directory,
+// using a lock file renewed by the leader.
+//
+// Standbys consider the lease expired when the lock file has not changed for
+// a whole lease duration, measured with their own clock, so that clock skew
+// between hosts does not matter.
+//
+// Reading, checking and writing the lease happen while holding the guard
+// file, which is created with O_EXCL. A guard left behind by a crashed
+// replica is removed once its content has not changed for a lease duration.
+type leaderElector struct {
+	path          string
+	guardPath     string
+	identity      string
+	leaseDuration time.Duration
+
+	// guardObserved is the content of a guard held by another replica,
+	// first seen at guardObservedAt
+	guardObserved   string
+	guardObservedAt time.Time
+
+	mu         sync.RWMutex
+	leader     bool
+	observed   leaseRecord
+	observedAt time.Time
+	// changes receives the new leadership state on each transition
+	changes chan bool
+}
+
+func newLeaderElector(dir, identity string, leaseDuration time.Duration) *leaderElector {
+	return &leaderElector{
+		path:          filepath.Join(dir, leaseFile),
+		guardPath:     filepath.Join(dir, leaseGuardFile),
+		identity:      identity,
+		leaseDuration: leaseDuration,
+		changes:       make(chan bool, 1),
+	}
+}
+
+// IsLeader reports whether this replica currently holds the lease.
+func (e *leaderElector) IsLeader() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.leader
+}
+
+// run acquires and renews the lease until the context is cancelled, then
+// releases it so a standby can take over right away.
+func (e *leaderElector) run(ctx context.Context) {
+	ticker := time.NewTicker(e.leaseDuration / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			e.release()
+			return
+		case <-ticker.C:
+			e.tryAcquireOrRenew()
+		}
+	}
+}
+
+func (e *leaderElector) tryAcquireOrRenew() {
+	unlock, err := e.lock()
+	if errors.Is(err, errLeaseBusy) {
+		// Another replica is updating the lease, retry at the next tick
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to lock leader lease", "file", e.guardPath, "error", err)
+		e.setLeader(false)
+		return
+	}
+	defer unlock()
+
+	now := time.Now()
+	current, err := e.read()
+	exists := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logger.Error("Failed to read leader lease", "file", e.path, "error", err)
+		e.setLeader(false)
+		return
+	}
+
+	record := leaseRecord{
+		Holder:        e.identity,
+		AcquiredAt:    now,
+		RenewedAt:     now,
+		LeaseDuration: e.leaseDuration.String(),
+	}
+	if exists {
+		if current.Holder != e.identity {
+			if !current.equal(e.observed) {
+				e.observed = current
+				e.observedAt = now
+			}
+			if now.Sub(e.observedAt) < e.leaseDuration {
+				e.setLeader(false)
+				return
+			}
+			logger.Info("Leader lease expired, taking over", "previous", current.Holder)
+		} else {
+			record.AcquiredAt = current.AcquiredAt
+		}
+	}
+
+	if err := e.write(record); err != nil {
+		logger.Error("Failed to write leader lease", "file", e.path, "error", err)
+		e.setLeader(false)
+		return
+	}
+
+	// Read the lease back to detect a replica that took over concurrently
+	written, err := e.read()
+	if err != nil || written.Holder != e.identity {
+		e.setLeader(false)
+		return
+	}
+	e.observed = written
+	e.observedAt = now
+	e.setLeader(true)
+}
+
+func (e *leaderElector) setLeader(leader bool) {
+	e.mu.Lock()
+	changed := e.leader != leader
+	e.leader = leader
+	e.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if leader {
+		logger.Info("Acquired leader lease, this replica is now the leader", "identity", e.identity)
+	} else {
+		logger.Info("Lost leader lease, this replica is now a standby", "identity", e.identity)
+	}
+	select {
+	case e.changes <- leader:
+	default:
+	}
+}
+
+func (e *leaderElector) release() {
+	if !e.IsLeader() {
+		return
+	}
+	unlock, err := e.lock()
+	if err != nil {
+		// The lease expires on its own after a lease duration
+		logger.Error("Failed to lock leader lease", "file", e.guardPath, "error", err)
+		e.setLeader(false)
+		return
+	}
+	defer unlock()
+	if current, err := e.read(); err == nil && current.Holder == e.identity {
+		if err := os.Remove(e.path); err != nil {
+			logger.Error("Failed to release leader lease", "file", e.path, "error", err)
+			return
+		}
+		logger.Info("Released leader lease", "identity", e.identity)
+	}
+	e.setLeader(false)
+}
+
+// lock creates the guard file exclusively and returns the function removing
+// it. It returns errLeaseBusy when another replica holds the guard.
+func (e *leaderElector) lock() (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(e.guardPath), 0755); err != nil {
+		return nil, err
+	}
+	token := fmt.Sprintf("%s %d", e.identity, time.Now().UnixNano())
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(e.guardPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, err = f.WriteString(token)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				_ = os.Remove(e.guardPath)
+				return nil, err
+			}
+			e.guardObserved, e.guardObservedAt = "", time.Time{}
+			return func() {
+				// Only remove our own guard, in case it was taken over as stale
+				if data, err := os.ReadFile(e.guardPath); err == nil && string(data) == token {
+					_ = os.Remove(e.guardPath)
+				}
+			}, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+		if !e.removeStaleGuard() {
+			return nil, errLeaseBusy
+		}
+	}
+	return nil, errLeaseBusy
+}
+
+// removeStaleGuard removes the guard of another replica when its content has
+// not changed for a whole lease duration, and reports whether it did.
+func (e *leaderElector) removeStaleGuard() bool {
+	data, err := os.ReadFile(e.guardPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return true
+	}
+	if err != nil {
+		return false
+	}
+	now := time.Now()
+	if e.guardObservedAt.IsZero() || string(data) != e.guardObserved {
+		e.guardObserved = string(data)
+		e.guardObservedAt = now
+		return false
+	}
+	if now.Sub(e.guardObservedAt) < e.leaseDuration {
+		return false
+	}
+	logger.Warn("Removing stale leader lease guard", "file", e.guardPath, "holder", e.guardObserved)
+	e.guardObserved, e.guardObservedAt = "", time.Time{}
+	if err := os.Remove(e.guardPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false
+	}
+	return true
+}
+
+func (e *leaderElector) read() (leaseRecord, error) {
+	var record leaseRecord
+	data, err := os.ReadFile(e.path)
+	if err != nil {
+		return record, err
+	}
+	err = json.Unmarshal(data, &record)
+	return record, err
+}
+
+// write replaces the lock file atomically.
+func (e *leaderElector) write(record leaseRecord) error {
+	if err := os.MkdirAll(filepath.Dir(e.path), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(e.path), leaseFile+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), e.path)
+}
@@ -0,0 +1,137 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLeaderElectionSingleLeader(t *testing.T) {
+	dir := t.TempDir()
+	a := newLeaderElector(dir, "a", time.Minute)
+	b := newLeaderElector(dir, "b", time.Minute)
+
+	a.tryAcquireOrRenew()
+	b.tryAcquireOrRenew()
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatalf("leaders = a:%v b:%v, want only a", a.IsLeader(), b.IsLeader())
+	}
+
+	// Renewing keeps the original acquisition time
+	acquired := a.observed.AcquiredAt
+	a.tryAcquireOrRenew()
+	if !a.IsLeader() || !a.observed.AcquiredAt.Equal(acquired) {
+		t.Errorf("renewal lost the lease or changed acquiredAt")
+	}
+	if _, err := os.Stat(a.guardPath); !os.IsNotExist(err) {
+		t.Errorf("guard file left behind after renewal: %v", err)
+	}
+}
+
+func TestLeaderElectionTakeOverExpiredLease(t *testing.T) {
+	dir := t.TempDir()
+	a := newLeaderElector(dir, "a", time.Minute)
+	b := newLeaderElector(dir, "b", time.Minute)
+
+	a.tryAcquireOrRenew()
+	b.tryAcquireOrRenew()
+	if b.IsLeader() {
+		t.Fatal("b took over a fresh lease")
+	}
+
+	// The lease has not changed for a whole lease duration
+	b.observedAt = b.observedAt.Add(-time.Minute)
+	b.tryAcquireOrRenew()
+	if !b.IsLeader() {
+		t.Fatal("b did not take over the expired lease")
+	}
+
+	a.tryAcquireOrRenew()
+	if a.IsLeader() {
+		t.Error("a is still leader after b took over")
+	}
+}
+
+func TestLeaderElectionRelease(t *testing.T) {
+	dir := t.TempDir()
+	a := newLeaderElector(dir, "a", time.Minute)
+	b := newLeaderElector(dir, "b", time.Minute)
+
+	a.tryAcquireOrRenew()
+	b.tryAcquireOrRenew()
+	a.release()
+	if a.IsLeader() {
+		t.Error("a is still leader after release")
+	}
+	if _, err := os.Stat(a.path); !os.IsNotExist(err) {
+		t.Errorf("lease file left behind after release: %v", err)
+	}
+
+	b.tryAcquireOrRenew()
+	if !b.IsLeader() {
+		t.Error("b did not acquire the released lease")
+	}
+}
+
+func TestLeaderElectionGuard(t *testing.T) {
+	dir := t.TempDir()
+	a := newLeaderElector(dir, "a", time.Minute)
+	b := newLeaderElector(dir, "b", time.Minute)
+
+	unlock, err := b.lock()
+	if err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+
+	// a does not touch the lease while b holds the guard
+	a.tryAcquireOrRenew()
+	if a.IsLeader() {
+		t.Fatal("a acquired the lease while b held the guard")
+	}
+	if _, err := os.Stat(a.path); !os.IsNotExist(err) {
+		t.Fatalf("lease written while the guard was held: %v", err)
+	}
+	if _, err := a.lock(); err != errLeaseBusy {
+		t.Fatalf("lock error = %v, want errLeaseBusy", err)
+	}
+
+	// A guard unchanged for a whole lease duration is stale
+	a.guardObservedAt = a.guardObservedAt.Add(-time.Minute)
+	a.tryAcquireOrRenew()
+	if !a.IsLeader() {
+		t.Fatal("a did not remove the stale guard")
+	}
+
+	// b no longer owns the guard and must not remove it
+	if err := os.WriteFile(a.guardPath, []byte("other"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	unlock()
+	if _, err := os.Stat(a.guardPath); err != nil {
+		t.Errorf("unlock removed a guard it does not own: %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// metrics holds the provider counters and gauges, exposed in the Prometheus
+// text format on the status endpoint.
+type metrics struct {
+	syncs      *counterVec
+	syncErrors *counterVec
+	// hostPolicyRejections and routePolicyDecisions count the routes
+	// rejected and changed by the last sync
+	hostPolicyRejections *gaugeVec
+	routePolicyDecisions *gaugeVec
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		syncs:                newCounterVec("goma_provider_syncs_total", "Number of sync passes."),
+		syncErrors:           newCounterVec("goma_provider_sync_errors_total", "Number of failed sync passes."),
+		hostPolicyRejections: newGaugeVec("goma_provider_host_policy_rejections", "Number of routes currently rejected by the host policy.", "source", "host"),
+		routePolicyDecisions: newGaugeVec("goma_provider_route_policy_decisions", "Number of route policy decisions of the last sync, by rule and action: reject, set or append.", "rule", "action"),
+	}
+}
+
+func (m *metrics) write(w io.Writer) {
+	for _, c := range []*counterVec{m.syncs, m.syncErrors, m.hostPolicyRejections.counterVec, m.routePolicyDecisions.counterVec} {
+		c.write(w)
+	}
+}
+
+// gauges returns the gauges measured by each sync.
+func (m *metrics) gauges() []*gaugeVec {
+	return []*gaugeVec{m.hostPolicyRejections, m.routePolicyDecisions}
+}
+
+// beginSync discards the gauge values of an unfinished sync.
+func (m *metrics) beginSync() {
+	for _, g := range m.gauges() {
+		g.discard()
+	}
+}
+
+// commitSync publishes the gauge values measured by a completed sync.
+func (m *metrics) commitSync() {
+	for _, g := range m.gauges() {
+		g.commit()
+	}
+}
+
+// counterVec is a counter partitioned by label values.
+type counterVec struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]uint64
+}
+
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	return &counterVec{name: name, help: help, kind: "counter", labels: labels, values: make(map[string]uint64)}
+}
+
+// inc increments the counter for the given label values.
+func (c *counterVec) inc(values ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[strings.Join(values, "\xff")]++
+}
+
+func (c *counterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", c.name, c.help, c.name, c.kind)
+	if len(c.labels) == 0 {
+		_, _ = fmt.Fprintf(w, "%s %d\n", c.name, c.values[""])
+		return
+	}
+
+	keys := make([]string, 0, len(c.values))
+	for key := range c.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		values := strings.Split(key, "\xff")
+		pairs := make([]string, len(c.labels))
+		for i, label := range c.labels {
+			pairs[i] = fmt.Sprintf("%s=%q", label, values[i])
+		}
+		_, _ = fmt.Fprintf(w, "%s{%s} %d\n", c.name, strings.Join(pairs, ","), c.values[key])
+	}
+}
+
+// gaugeVec is a gauge partitioned by label values, measured by each sync:
+// the values added during a sync replace the previous ones once it completes,
+// so that a route rejected on every sync is counted once.
+type gaugeVec struct {
+	*counterVec
+	pending map[string]uint64
+}
+
+func newGaugeVec(name, help string, labels ...string) *gaugeVec {
+	c := newCounterVec(name, help, labels...)
+	c.kind = "gauge"
+	return &gaugeVec{counterVec: c, pending: make(map[string]uint64)}
+}
+
+// add increments the gauge of the current sync for the given label values.
+func (g *gaugeVec) add(values ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.pending[strings.Join(values, "\xff")]++
+}
+
+func (g *gaugeVec) commit() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.values = g.pending
+	g.pending = make(map[string]uint64)
+}
+
+func (g *gaugeVec) discard() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.pending = make(map[string]uint64)
+}
@@ -0,0 +1,244 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/jkaninda/goma-docker-provider/internal/config"
+	"github.com/jkaninda/logger"
+)
+
+const (
+	notificationQueueSize  = 100
+	defaultWebhookRetries  = 5
+	defaultWebhookTimeout  = 10 * time.Second
+	webhookInitialBackoff  = time.Second
+	webhookMaxBackoff      = time.Minute
+	webhookSignatureHeader = "X-Goma-Signature"
+	webhookEventHeader     = "X-Goma-Event"
+	routesChangedEventName = "routes.changed"
+)
+
+// ChangeEvent describes a change of the generated route set.
+type ChangeEvent struct {
+	Event     string        `json:"event"`
+	Timestamp time.Time     `json:"timestamp"`
+	Identity  string        `json:"identity,omitempty"`
+	Hash      string        `json:"hash"`
+	Added     []RouteChange `json:"added"`
+	Removed   []RouteChange `json:"removed"`
+	Modified  []RouteChange `json:"modified"`
+}
+
+// RouteChange describes a route that was added, removed or modified, and the
+// container or service that caused the change.
+type RouteChange struct {
+	Name   string `json:"name"`
+	Source string `json:"source,omitempty"`
+	Before *Route `json:"before,omitempty"`
+	After  *Route `json:"after,omitempty"`
+}
+
+// Empty reports whether the event has no change.
+func (e ChangeEvent) Empty() bool {
+	return len(e.Added) == 0 && len(e.Removed) == 0 && len(e.Modified) == 0
+}
+
+// diffRoutes compares two route sets by route name.
+func diffRoutes(previous, current []Route) ChangeEvent {
+	event := ChangeEvent{
+		Event:    routesChangedEventName,
+		Added:    make([]RouteChange, 0),
+		Removed:  make([]RouteChange, 0),
+		Modified: make([]RouteChange, 0),
+	}
+
+	before := make(map[string]Route, len(previous))
+	for _, route := range previous {
+		before[route.Name] = route
+	}
+	after := make(map[string]bool, len(current))
+	for _, route := range current {
+		after[route.Name] = true
+		old, exists := before[route.Name]
+		switch {
+		case !exists:
+			event.Added = append(event.Added, RouteChange{Name: route.Name, Source: route.Source, After: &route})
+		case !sameRoute(old, route):
+			event.Modified = append(event.Modified, RouteChange{Name: route.Name, Source: route.Source, Before: &old, After: &route})
+		}
+	}
+	for _, route := range previous {
+		if !after[route.Name] {
+			event.Removed = append(event.Removed, RouteChange{Name: route.Name, Source: route.Source, Before: &route})
+		}
+	}
+	return event
+}
+
+func sameRoute(a, b Route) bool {
+	left, _ := json.Marshal(a)
+	right, _ := json.Marshal(b)
+	return bytes.Equal(left, right)
+}
+
+// readRoutes reads the routes of a generated configuration file, it returns
+// no routes when the file does not exist.
+func readRoutes(path string) []Route {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return parseRoutes(data)
+}
+
+// parseRoutes parses the routes of a generated configuration, whatever
+// gateway version it was generated for.
+func parseRoutes(data []byte) []Route {
+	routes, err := lookupTargetVersion(fileVersion(data)).decode(data)
+	if err != nil {
+		return nil
+	}
+	return routes
+}
+
+// notifier delivers change events to webhooks in the background, so that slow
+// or failing endpoints never block the sync loop.
+type notifier struct {
+	webhooks []config.Webhook
+	queue    chan ChangeEvent
+	client   *http.Client
+	// backoff is the delay before the first retry, doubled on each retry
+	backoff time.Duration
+}
+
+func newNotifier(webhooks []config.Webhook) *notifier {
+	return &notifier{
+		webhooks: webhooks,
+		queue:    make(chan ChangeEvent, notificationQueueSize),
+		client:   &http.Client{},
+		backoff:  webhookInitialBackoff,
+	}
+}
+
+// notify queues an event, dropping it when the queue is full.
+func (n *notifier) notify(event ChangeEvent) {
+	select {
+	case n.queue <- event:
+	default:
+		logger.Error("Webhook queue is full, dropping change notification", "hash", event.Hash)
+	}
+}
+
+func (n *notifier) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-n.queue:
+			body, err := json.Marshal(event)
+			if err != nil {
+				logger.Error("Failed to marshal change notification", "error", err)
+				continue
+			}
+			for _, webhook := range n.webhooks {
+				n.deliver(ctx, webhook, body)
+			}
+		}
+	}
+}
+
+// deliver posts the payload to a webhook, retrying with exponential backoff.
+func (n *notifier) deliver(ctx context.Context, webhook config.Webhook, body []byte) {
+	retries := webhook.MaxRetries
+	switch {
+	case retries == 0:
+		retries = defaultWebhookRetries
+	case retries < 0:
+		retries = 0
+	}
+	backoff := n.backoff
+
+	for attempt := 0; ; attempt++ {
+		err := n.post(ctx, webhook, body)
+		if err == nil {
+			logger.Debug("Change notification delivered", "url", webhook.URL)
+			return
+		}
+		if attempt >= retries {
+			logger.Error("Change notification failed, giving up", "url", webhook.URL, "attempts", attempt+1, "error", err)
+			return
+		}
+		logger.Warn("Change notification failed, retrying", "url", webhook.URL, "retry_in", backoff, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, webhookMaxBackoff)
+	}
+}
+
+func (n *notifier) post(ctx context.Context, webhook config.Webhook, body []byte) error {
+	timeout := webhook.Timeout
+	if timeout == 0 {
+		timeout = defaultWebhookTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookEventHeader, routesChangedEventName)
+	if webhook.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(webhook.Secret))
+		mac.Write(body)
+		req.Header.Set(webhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jkaninda/goma-docker-provider/internal/config"
+)
+
+func TestDiffRoutes(t *testing.T) {
+	previous := []Route{
+		{Name: "kept", Path: "/", Target: "http://kept:80"},
+		{Name: "changed", Path: "/", Target: "http://changed:80"},
+		{Name: "removed", Path: "/", Target: "http://removed:80", Source: "old"},
+	}
+	current := []Route{
+		{Name: "kept", Path: "/", Target: "http://kept:80"},
+		{Name: "changed", Path: "/", Target: "http://changed:8080"},
+		{Name: "added", Path: "/", Target: "http://added:80", Source: "new"},
+	}
+	event := diffRoutes(previous, current)
+	if event.Event != routesChangedEventName || event.Empty() {
+		t.Fatalf("event = %+v", event)
+	}
+	if len(event.Added) != 1 || event.Added[0].Name != "added" || event.Added[0].Source != "new" || event.Added[0].After == nil {
+		t.Errorf("added = %+v", event.Added)
+	}
+	if len(event.Removed) != 1 || event.Removed[0].Name != "removed" || event.Removed[0].Before == nil {
+		t.Errorf("removed = %+v", event.Removed)
+	}
+	if len(event.Modified) != 1 || event.Modified[0].Before.Target != "http://changed:80" || event.Modified[0].After.Target != "http://changed:8080" {
+		t.Errorf("modified = %+v", event.Modified)
+	}
+
+	if !diffRoutes(current, current).Empty() {
+		t.Error("identical route sets are not empty")
+	}
+}
+
+// newTestNotifier returns a notifier retrying without delay.
+func newTestNotifier(webhooks ...config.Webhook) *notifier {
+	n := newNotifier(webhooks)
+	n.backoff = time.Millisecond
+	return n
+}
+
+func TestNotifierRetries(t *testing.T) {
+	var attempts atomic.Int32
+	var signature, event string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		signature = r.Header.Get(webhookSignatureHeader)
+		event = r.Header.Get(webhookEventHeader)
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	webhook := config.Webhook{URL: server.URL, Secret: "s3cret", MaxRetries: 3}
+	payload := []byte(`{"event":"routes.changed"}`)
+	newTestNotifier(webhook).deliver(context.Background(), webhook, payload)
+
+	if got := attempts.Load(); got != 3 {
+		t.Fatalf("attempts = %d, want 3", got)
+	}
+	if string(body) != string(payload) || event != routesChangedEventName {
+		t.Errorf("delivered %s %q, want %s", event, body, payload)
+	}
+	mac := hmac.New(sha256.New, []byte(webhook.Secret))
+	mac.Write(payload)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); signature != want {
+		t.Errorf("signature = %q, want %q", signature, want)
+	}
+}
+
+func TestNotifierGivesUp(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	webhook := config.Webhook{URL: server.URL, MaxRetries: 2}
+	newTestNotifier(webhook).deliver(context.Background(), webhook, []byte("{}"))
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("attempts = %d, want the first attempt and 2 retries", got)
+	}
+}
+
+func TestNotifierMaxRetries(t *testing.T) {
+	tests := []struct {
+		maxRetries int
+		want       int32
+	}{
+		{0, defaultWebhookRetries + 1},
+		{-1, 1},
+		{1, 2},
+	}
+	for _, tt := range tests {
+		var attempts atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts.Add(1)
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		webhook := config.Webhook{URL: server.URL, MaxRetries: tt.maxRetries}
+		newTestNotifier(webhook).deliver(context.Background(), webhook, []byte("{}"))
+		server.Close()
+		if got := attempts.Load(); got != tt.want {
+			t.Errorf("maxRetries %d: attempts = %d, want %d", tt.maxRetries, got, tt.want)
+		}
+	}
+}
+
+func TestNotifierQueue(t *testing.T) {
+	delivered := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivered <- r.URL.Path
+	}))
+	defer server.Close()
+
+	n := newTestNotifier(config.Webhook{URL: server.URL + "/a"}, config.Webhook{URL: server.URL + "/b"})
+	for range notificationQueueSize + 1 {
+		// The last event is dropped instead of blocking
+		n.notify(ChangeEvent{Event: routesChangedEventName})
+	}
+	if len(n.queue) != notificationQueueSize {
+		t.Fatalf("queue length = %d, want %d", len(n.queue), notificationQueueSize)
+	}
+	for len(n.queue) > 1 {
+		<-n.queue
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go n.run(ctx)
+	for _, want := range []string{"/a", "/b"} {
+		select {
+		case got := <-delivered:
+			if got != want {
+				t.Errorf("delivered to %s, want %s", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("event not delivered to %s", want)
+		}
+	}
+}
@@ -0,0 +1,444 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Policy is the provider policy file.
+type Policy struct {
+	// Hosts restricts which sources may publish routes on which hosts.
+	Hosts HostPolicy `yaml:"hosts"`
+	// Routes enforces route settings whatever the labels say.
+	Routes RoutePolicy `yaml:"routes"`
+}
+
+// HostPolicy maps host patterns to the sources allowed to claim them.
+type HostPolicy struct {
+	// Rules are evaluated in order, the first rule matching a host applies.
+	Rules []HostRule `yaml:"rules"`
+	// Default applies to hosts matching no rule: allow (default) or deny.
+	Default string `yaml:"default"`
+}
+
+// HostRule allows a set of sources to publish routes on matching hosts.
+type HostRule struct {
+	// Host is an exact host or a wildcard such as *.example.com, which
+	// matches any subdomain.
+	Host string `yaml:"host,omitempty"`
+	// Regex is a regular expression matched against the whole host.
+	Regex string `yaml:"regex,omitempty"`
+	// Projects lists the allowed compose projects.
+	Projects []string `yaml:"projects,omitempty"`
+	// Stacks lists the allowed Swarm stacks.
+	Stacks []string `yaml:"stacks,omitempty"`
+	// Images lists the allowed image repositories, * globs are supported.
+	Images []string `yaml:"images,omitempty"`
+
+	regex *regexp.Regexp
+}
+
+// RoutePolicy changes or rejects routes after their labels and defaults
+// are merged, labels cannot override it.
+type RoutePolicy struct {
+	// Rules are all applied, in order, to the routes they match.
+	Rules []RouteRule `yaml:"rules"`
+}
+
+// RouteRule changes or rejects the routes it matches.
+type RouteRule struct {
+	// Name identifies the rule in logs and metrics, defaults to its position.
+	Name string `yaml:"name,omitempty"`
+	// Hosts matches the routes with at least one matching host, exact or a
+	// wildcard such as *.example.com. Routes match whatever their hosts
+	// when empty, and routes without hosts, which answer every host,
+	// match every rule.
+	Hosts []string `yaml:"hosts,omitempty"`
+	// Projects, Stacks and Images match the routes of these sources, as in
+	// host rules. Routes match whatever their source when all are empty.
+	Projects []string `yaml:"projects,omitempty"`
+	Stacks   []string `yaml:"stacks,omitempty"`
+	Images   []string `yaml:"images,omitempty"`
+	// Middlewares are appended to the middlewares of matching routes.
+	Middlewares []string `yaml:"middlewares,omitempty"`
+	// Set forces route fields, keyed by label such as goma.disable_metrics.
+	Set map[string]string `yaml:"set,omitempty"`
+	// Forbid rejects the routes with a forbidden field value, keyed by
+	// label. The value * forbids setting the field at all.
+	Forbid map[string]string `yaml:"forbid,omitempty"`
+}
+
+// routeOwner identifies who published a route.
+type routeOwner struct {
+	project string
+	stack   string
+	image   string
+}
+
+func (o routeOwner) String() string {
+	parts := make([]string, 0, 3)
+	if o.project != "" {
+		parts = append(parts, "project="+o.project)
+	}
+	if o.stack != "" {
+		parts = append(parts, "stack="+o.stack)
+	}
+	if o.image != "" {
+		parts = append(parts, "image="+o.image)
+	}
+	return strings.Join(parts, " ")
+}
+
+// containerOwner returns the owner of a container from its Compose and Swarm labels.
+func containerOwner(labels map[string]string, image string) routeOwner {
+	return routeOwner{
+		project: labels["com.docker.compose.project"],
+		stack:   labels["com.docker.stack.namespace"],
+		image:   imageRepository(image),
+	}
+}
+
+// imageRepository strips the tag and digest of an image reference.
+func imageRepository(image string) string {
+	image, _, _ = strings.Cut(image, "@")
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image
+}
+
+// LoadPolicy reads and validates a policy file.
+func LoadPolicy(file string) (*Policy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+	var policy Policy
+	if err := yaml.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file %s: %w", file, err)
+	}
+	if err := policy.compile(); err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %w", file, err)
+	}
+	return &policy, nil
+}
+
+func (p *Policy) compile() error {
+	var errs []error
+	switch p.Hosts.Default {
+	case "", "allow", "deny":
+	default:
+		errs = append(errs, fmt.Errorf("hosts.default must be allow or deny, got %q", p.Hosts.Default))
+	}
+	for i := range p.Hosts.Rules {
+		rule := &p.Hosts.Rules[i]
+		if (rule.Host == "") == (rule.Regex == "") {
+			errs = append(errs, fmt.Errorf("hosts.rules[%d]: exactly one of host or regex is required", i))
+			continue
+		}
+		if rule.Regex != "" {
+			re, err := regexp.Compile("^(?:" + rule.Regex + ")$")
+			if err != nil {
+				errs = append(errs, fmt.Errorf("hosts.rules[%d]: invalid regex: %w", i, err))
+				continue
+			}
+			rule.regex = re
+		}
+		for _, image := range rule.Images {
+			if _, err := path.Match(image, ""); err != nil {
+				errs = append(errs, fmt.Errorf("hosts.rules[%d]: invalid image pattern %q", i, image))
+			}
+		}
+	}
+	for i := range p.Routes.Rules {
+		errs = append(errs, p.Routes.Rules[i].compile(i)...)
+	}
+	return errors.Join(errs...)
+}
+
+func (r *RouteRule) compile(i int) []error {
+	if r.Name == "" {
+		r.Name = fmt.Sprintf("routes.rules[%d]", i)
+	}
+	var errs []error
+	if len(r.Middlewares) == 0 && len(r.Set) == 0 && len(r.Forbid) == 0 {
+		errs = append(errs, fmt.Errorf("%s: one of middlewares, set or forbid is required", r.Name))
+	}
+	for _, image := range r.Images {
+		if _, err := path.Match(image, ""); err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid image pattern %q", r.Name, image))
+		}
+	}
+	for _, key := range sortedKeys(r.Set) {
+		field, err := policyField(key)
+		if err == nil {
+			err = field.check(r.Set[key])
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: set: %s: %w", r.Name, key, err))
+		}
+	}
+	for _, key := range sortedKeys(r.Forbid) {
+		field, err := policyField(key)
+		if err == nil && r.Forbid[key] != "*" {
+			err = field.check(r.Forbid[key])
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: forbid: %s: %w", r.Name, key, err))
+		}
+	}
+	return errs
+}
+
+// policyField returns the route field a route rule label applies to.
+func policyField(key string) (routeField, error) {
+	field, ok := lookupRouteField(strings.TrimPrefix(key, "goma."))
+	if !ok || !strings.HasPrefix(key, "goma.") {
+		return routeField{}, errors.New("unknown label")
+	}
+	if !inheritedLabel(key) {
+		return routeField{}, errors.New("label cannot be enforced")
+	}
+	return field, nil
+}
+
+func (r *RouteRule) matches(hosts []string, owner routeOwner) bool {
+	if len(r.Hosts) > 0 && len(hosts) > 0 {
+		matched := false
+		for _, host := range hosts {
+			for _, pattern := range r.Hosts {
+				if matchHostPattern(pattern, strings.ToLower(host)) {
+					matched = true
+				}
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(r.Projects) > 0 || len(r.Stacks) > 0 || len(r.Images) > 0 {
+		return matchOwner(r.Projects, r.Stacks, r.Images, owner)
+	}
+	return true
+}
+
+// forbids reports whether a route rule forbids the value of a field, unset
+// fields have their built-in value.
+func (r *RouteRule) forbids(key string, fields map[string]string) (string, bool) {
+	field, _ := lookupRouteField(strings.TrimPrefix(key, "goma."))
+	forbidden := r.Forbid[key]
+	value, set := fields[field.key]
+	if forbidden == "*" {
+		return value, set
+	}
+	if !set {
+		value = field.builtin
+	}
+	switch field.kind {
+	case kindBoolean:
+		parsed, err := strconv.ParseBool(value)
+		want, _ := strconv.ParseBool(forbidden)
+		return value, err == nil && parsed == want
+	case kindList, kindIntList:
+		return value, slices.Contains(parseList(value), forbidden)
+	}
+	return value, value == forbidden
+}
+
+func (r *HostRule) matchHost(host string) bool {
+	if r.regex != nil {
+		return r.regex.MatchString(host)
+	}
+	return matchHostPattern(r.Host, host)
+}
+
+// matchHostPattern matches a lower case host against an exact host or a
+// wildcard such as *.example.com, which matches any subdomain.
+func matchHostPattern(pattern, host string) bool {
+	pattern = strings.ToLower(pattern)
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return host == pattern
+}
+
+func (r *HostRule) allows(owner routeOwner) bool {
+	return matchOwner(r.Projects, r.Stacks, r.Images, owner)
+}
+
+// matchOwner reports whether the owner is one of the projects or stacks,
+// or runs one of the image patterns.
+func matchOwner(projects, stacks, images []string, owner routeOwner) bool {
+	for _, project := range projects {
+		if owner.project != "" && project == owner.project {
+			return true
+		}
+	}
+	for _, stack := range stacks {
+		if owner.stack != "" && stack == owner.stack {
+			return true
+		}
+	}
+	for _, image := range images {
+		if matched, _ := path.Match(image, owner.image); matched && owner.image != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// allowHost reports whether the owner may publish routes on the host.
+func (h *HostPolicy) allowHost(host string, owner routeOwner) bool {
+	host = strings.ToLower(host)
+	for i := range h.Rules {
+		if h.Rules[i].matchHost(host) {
+			return h.Rules[i].allows(owner)
+		}
+	}
+	return h.Default != "deny"
+}
+
+// catchAllHost is the host a route without hosts claims, as it answers
+// every host.
+const catchAllHost = "*"
+
+// enforceHostPolicy drops the routes claiming hosts their source is not
+// allowed to use. Routes without hosts claim the catch-all host *.
+func (p *Provider) enforceHostPolicy(source string, owner routeOwner, routes []Route) []Route {
+	if p.policy == nil {
+		return routes
+	}
+	allowed := make([]Route, 0, len(routes))
+	for _, route := range routes {
+		hosts := route.Hosts
+		if len(hosts) == 0 {
+			hosts = []string{catchAllHost}
+		}
+		rejected := ""
+		for _, host := range hosts {
+			if !p.policy.Hosts.allowHost(host, owner) {
+				rejected = host
+				break
+			}
+		}
+		if rejected != "" {
+			p.errorf(source, "", "route %q rejected by host policy: host %q is not allowed for %s", route.Name, rejected, ownerDescription(owner))
+			p.metrics.hostPolicyRejections.add(source, rejected)
+			continue
+		}
+		allowed = append(allowed, route)
+	}
+	return allowed
+}
+
+// applyRoutePolicy applies the route rules matching a route to its fields
+// and records the source of the values it sets. It reports false when the
+// route is rejected.
+func (p *Provider) applyRoutePolicy(fc fieldContext, name string, owner routeOwner, fields, sources map[string]string) bool {
+	if p.policy == nil {
+		return true
+	}
+	for i := range p.policy.Routes.Rules {
+		rule := &p.policy.Routes.Rules[i]
+		if !rule.matches(parseList(fields["hosts"]), owner) {
+			continue
+		}
+		source := fmt.Sprintf("policy (%s)", rule.Name)
+
+		for _, key := range sortedKeys(rule.Forbid) {
+			if value, forbidden := rule.forbids(key, fields); forbidden {
+				field := strings.TrimPrefix(key, "goma.")
+				p.errorf(fc.source, fc.key(field), "route %q rejected by route policy %q: value %q is forbidden", name, rule.Name, value)
+				p.metrics.routePolicyDecisions.add(rule.Name, "reject")
+				return false
+			}
+		}
+
+		for _, key := range sortedKeys(rule.Set) {
+			field := strings.TrimPrefix(key, "goma.")
+			value := rule.Set[key]
+			current, set := fields[field]
+			if set && current == value {
+				continue
+			}
+			if set && sources[field] == sourceLabel {
+				p.warnf(fc.source, fc.key(field), "value %q of route %q overridden with %q by route policy %q", current, name, value, rule.Name)
+			} else {
+				p.infof(fc.source, fc.key(field), "set to %q on route %q by route policy %q", value, name, rule.Name)
+			}
+			fields[field] = value
+			sources[field] = source
+			p.metrics.routePolicyDecisions.add(rule.Name, "set")
+		}
+
+		if len(rule.Middlewares) > 0 {
+			middlewares := parseList(fields["middlewares"])
+			added := make([]string, 0, len(rule.Middlewares))
+			for _, middleware := range rule.Middlewares {
+				if !slices.Contains(middlewares, middleware) {
+					middlewares = append(middlewares, middleware)
+					added = append(added, middleware)
+				}
+			}
+			if len(added) > 0 {
+				if previous, ok := sources["middlewares"]; ok {
+					source = previous + ", " + source
+				}
+				fields["middlewares"] = strings.Join(middlewares, ",")
+				sources["middlewares"] = source
+				p.infof(fc.source, fc.key("middlewares"), "%s appended to route %q by route policy %q", strings.Join(added, ", "), name, rule.Name)
+				p.metrics.routePolicyDecisions.add(rule.Name, "append")
+			}
+		}
+	}
+	return true
+}
+
+// sortedKeys returns the keys of a map in order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func ownerDescription(owner routeOwner) string {
+	if description := owner.String(); description != "" {
+		return description
+	}
+	return "an unknown source"
+}
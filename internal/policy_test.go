@@ -0,0 +1,407 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/jkaninda/goma-docker-provider/internal/config"
+)
+
+// newTestPolicy compiles a policy the way LoadPolicy does.
+func newTestPolicy(t *testing.T, policy Policy) *Policy {
+	t.Helper()
+	if err := policy.compile(); err != nil {
+		t.Fatalf("invalid test policy: %v", err)
+	}
+	return &policy
+}
+
+func TestMatchHostPattern(t *testing.T) {
+	tests := []struct {
+		pattern, host string
+		want          bool
+	}{
+		{"example.com", "example.com", true},
+		{"Example.COM", "example.com", true},
+		{"example.com", "www.example.com", false},
+		{"*.example.com", "a.example.com", true},
+		{"*.example.com", "a.b.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", "badexample.com", false},
+		{"*", "*", true},
+		{"*", "example.com", false},
+	}
+	for _, tt := range tests {
+		if got := matchHostPattern(tt.pattern, tt.host); got != tt.want {
+			t.Errorf("matchHostPattern(%q, %q) = %v, want %v", tt.pattern, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestHostPolicyAllowHost(t *testing.T) {
+	policy := newTestPolicy(t, Policy{Hosts: HostPolicy{
+		Default: "deny",
+		Rules: []HostRule{
+			{Host: "payments.example.com", Projects: []string{"payments"}},
+			{Host: "*.shop.example.com", Stacks: []string{"shop"}, Images: []string{"registry.example.com/shop/*"}},
+			{Regex: `admin-[a-z]+\.example\.com`, Projects: []string{"ops"}},
+		},
+	}})
+	tests := []struct {
+		name  string
+		host  string
+		owner routeOwner
+		want  bool
+	}{
+		{"exact host, allowed project", "payments.example.com", routeOwner{project: "payments"}, true},
+		{"exact host, case insensitive", "Payments.Example.com", routeOwner{project: "payments"}, true},
+		{"exact host, other project", "payments.example.com", routeOwner{project: "shop"}, false},
+		{"wildcard host, allowed stack", "api.shop.example.com", routeOwner{stack: "shop"}, true},
+		{"wildcard host, allowed image", "api.shop.example.com", routeOwner{image: "registry.example.com/shop/api"}, true},
+		{"wildcard host, other image", "api.shop.example.com", routeOwner{image: "registry.example.com/blog/api"}, false},
+		{"regex host", "admin-eu.example.com", routeOwner{project: "ops"}, true},
+		{"regex matches the whole host", "admin-eu.example.com.evil.io", routeOwner{project: "ops"}, false},
+		{"no rule, default deny", "blog.example.com", routeOwner{project: "blog"}, false},
+		{"empty owner never matches", "payments.example.com", routeOwner{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.Hosts.allowHost(tt.host, tt.owner); got != tt.want {
+				t.Errorf("allowHost(%q, %v) = %v, want %v", tt.host, tt.owner, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnforceHostPolicyCatchAll(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy HostPolicy
+		want   []string
+	}{
+		{
+			name:   "default allow keeps routes without hosts",
+			policy: HostPolicy{},
+			want:   []string{"web", "api"},
+		},
+		{
+			name:   "default deny rejects routes without hosts",
+			policy: HostPolicy{Default: "deny", Rules: []HostRule{{Host: "api.example.com", Projects: []string{"shop"}}}},
+			want:   []string{"api"},
+		},
+		{
+			name: "catch-all rule allows the project",
+			policy: HostPolicy{Default: "deny", Rules: []HostRule{
+				{Host: "*", Projects: []string{"shop"}},
+				{Host: "api.example.com", Projects: []string{"shop"}},
+			}},
+			want: []string{"web", "api"},
+		},
+		{
+			name:   "catch-all rule for another project",
+			policy: HostPolicy{Default: "deny", Rules: []HostRule{{Host: "*", Projects: []string{"blog"}}}},
+			want:   nil,
+		},
+		{
+			name:   "wildcard rules do not allow the catch-all host",
+			policy: HostPolicy{Default: "deny", Rules: []HostRule{{Host: "*.example.com", Projects: []string{"shop"}}}},
+			want:   []string{"api"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewProvider(config.Default())
+			p.policy = newTestPolicy(t, Policy{Hosts: tt.policy})
+			routes := []Route{
+				{Name: "web"},
+				{Name: "api", Hosts: []string{"api.example.com"}},
+			}
+			allowed := p.enforceHostPolicy("web-1", routeOwner{project: "shop"}, routes)
+			var got []string
+			for _, route := range allowed {
+				got = append(got, route.Name)
+			}
+			if !equalStrings(got, tt.want) {
+				t.Errorf("allowed routes = %v, want %v", got, tt.want)
+			}
+			if rejected := len(routes) - len(allowed); rejected != len(p.diagnostics) {
+				t.Errorf("got %d diagnostics for %d rejected routes", len(p.diagnostics), rejected)
+			}
+		})
+	}
+}
+
+// equalStrings compares string slices, nil and empty are equal.
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRouteRuleMatches(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  RouteRule
+		hosts []string
+		owner routeOwner
+		want  bool
+	}{
+		{"empty rule", RouteRule{}, []string{"a.example.com"}, routeOwner{}, true},
+		{"matching wildcard host", RouteRule{Hosts: []string{"*.example.com"}}, []string{"other.io", "A.example.com"}, routeOwner{}, true},
+		{"no matching host", RouteRule{Hosts: []string{"*.example.com"}}, []string{"example.com"}, routeOwner{}, false},
+		{"route without hosts", RouteRule{Hosts: []string{"*.example.com"}}, nil, routeOwner{}, true},
+		{"route without hosts, other project", RouteRule{Hosts: []string{"*.example.com"}, Projects: []string{"shop"}}, nil, routeOwner{project: "blog"}, false},
+		{"matching project", RouteRule{Projects: []string{"shop"}}, nil, routeOwner{project: "shop"}, true},
+		{"matching image", RouteRule{Images: []string{"nginx*"}}, nil, routeOwner{image: "nginx"}, true},
+		{"host and owner must both match", RouteRule{Hosts: []string{"a.example.com"}, Stacks: []string{"shop"}}, []string{"a.example.com"}, routeOwner{stack: "blog"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.matches(tt.hosts, tt.owner); got != tt.want {
+				t.Errorf("matches(%v, %v) = %v, want %v", tt.hosts, tt.owner, got, tt.want)
+			}
+		})
+	}
+}
+
+// newSyncTestProvider returns a provider syncing the containers of a fake
+// Docker engine to a temporary output directory.
+func newSyncTestProvider(t *testing.T, containers ...container.Summary) *Provider {
+	t.Helper()
+	cfg := config.Default()
+	cfg.OutputDir = t.TempDir()
+	cfg.HistoryLimit = 0
+	p := NewProvider(cfg)
+	p.dockerClient = &fakeDocker{containers: containers}
+	return p
+}
+
+func TestHostPolicyRejectionsGauge(t *testing.T) {
+	web := container.Summary{ID: "web-id", Names: []string{"/web"}, State: "running", Labels: map[string]string{
+		"goma.enable":                "true",
+		"com.docker.compose.project": "shop",
+	}}
+	p := newSyncTestProvider(t, web)
+	p.policy = newTestPolicy(t, Policy{Hosts: HostPolicy{Default: "deny"}})
+
+	metric := `goma_provider_host_policy_rejections{source="web",host="*"} 1`
+	for range 3 {
+		if err := p.syncConfiguration(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		var out strings.Builder
+		p.metrics.write(&out)
+		if !strings.Contains(out.String(), metric) {
+			t.Fatalf("metrics do not contain %q:\n%s", metric, out.String())
+		}
+	}
+
+	// The gauge drops the route once it is allowed
+	p.policy = newTestPolicy(t, Policy{})
+	if err := p.syncConfiguration(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	p.metrics.write(&out)
+	if strings.Contains(out.String(), "goma_provider_host_policy_rejections{") {
+		t.Errorf("rejection still reported after the route was allowed:\n%s", out.String())
+	}
+}
+
+func TestApplyRoutePolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   RouteRule
+		labels map[string]string
+		// check inspects the route, rejected routes are nil
+		check    func(t *testing.T, route *Route)
+		severity Severity
+	}{
+		{
+			name:   "set a field",
+			rule:   RouteRule{Set: map[string]string{"goma.disable_metrics": "true"}},
+			labels: map[string]string{},
+			check: func(t *testing.T, route *Route) {
+				if route == nil || !route.DisableMetrics || route.FieldSources["disable_metrics"] != "policy (r)" {
+					t.Errorf("route = %+v, want metrics disabled by the policy", route)
+				}
+			},
+			severity: SeverityInfo,
+		},
+		{
+			name:   "set overrides a label",
+			rule:   RouteRule{Set: map[string]string{"goma.security.enable_exploit_protection": "true"}},
+			labels: map[string]string{"goma.security.enable_exploit_protection": "false"},
+			check: func(t *testing.T, route *Route) {
+				if route == nil || !route.Security.EnableExploitProtection {
+					t.Errorf("route = %+v, want exploit protection forced", route)
+				}
+			},
+			severity: SeverityWarning,
+		},
+		{
+			name:   "set matching the label",
+			rule:   RouteRule{Set: map[string]string{"goma.priority": "5"}},
+			labels: map[string]string{"goma.priority": "5"},
+			check: func(t *testing.T, route *Route) {
+				if route == nil || route.FieldSources["priority"] != sourceLabel {
+					t.Errorf("route = %+v, want the label kept", route)
+				}
+			},
+		},
+		{
+			name:   "forbid a value",
+			rule:   RouteRule{Forbid: map[string]string{"goma.security.tls.insecure_skip_verify": "true"}},
+			labels: map[string]string{"goma.security.tls.insecure_skip_verify": "true"},
+			check: func(t *testing.T, route *Route) {
+				if route != nil {
+					t.Errorf("route = %+v, want it rejected", route)
+				}
+			},
+			severity: SeverityError,
+		},
+		{
+			name:   "forbid a built-in value",
+			rule:   RouteRule{Forbid: map[string]string{"goma.security.enable_exploit_protection": "false"}},
+			labels: map[string]string{},
+			check: func(t *testing.T, route *Route) {
+				if route != nil {
+					t.Errorf("route = %+v, want it rejected", route)
+				}
+			},
+			severity: SeverityError,
+		},
+		{
+			name:   "forbid setting a field",
+			rule:   RouteRule{Forbid: map[string]string{"goma.rewrite": "*"}},
+			labels: map[string]string{"goma.rewrite": "/"},
+			check: func(t *testing.T, route *Route) {
+				if route != nil {
+					t.Errorf("route = %+v, want it rejected", route)
+				}
+			},
+			severity: SeverityError,
+		},
+		{
+			name:   "forbid an unset field",
+			rule:   RouteRule{Forbid: map[string]string{"goma.rewrite": "*"}},
+			labels: map[string]string{},
+			check: func(t *testing.T, route *Route) {
+				if route == nil {
+					t.Error("route rejected")
+				}
+			},
+		},
+		{
+			name:   "forbid a list value",
+			rule:   RouteRule{Forbid: map[string]string{"goma.methods": "DELETE"}},
+			labels: map[string]string{"goma.methods": "GET,DELETE"},
+			check: func(t *testing.T, route *Route) {
+				if route != nil {
+					t.Errorf("route = %+v, want it rejected", route)
+				}
+			},
+			severity: SeverityError,
+		},
+		{
+			name:   "append middlewares",
+			rule:   RouteRule{Middlewares: []string{"waf", "rate-limit"}},
+			labels: map[string]string{"goma.middlewares": "rate-limit"},
+			check: func(t *testing.T, route *Route) {
+				if route == nil || !equalStrings(route.Middlewares, []string{"rate-limit", "waf"}) {
+					t.Errorf("route = %+v, want waf appended", route)
+				}
+				if route != nil && route.FieldSources["middlewares"] != "label, policy (r)" {
+					t.Errorf("middlewares source = %q", route.FieldSources["middlewares"])
+				}
+			},
+			severity: SeverityInfo,
+		},
+		{
+			name:   "rule for other hosts",
+			rule:   RouteRule{Hosts: []string{"*.example.com"}, Set: map[string]string{"goma.disable_metrics": "true"}},
+			labels: map[string]string{"goma.hosts": "example.org"},
+			check: func(t *testing.T, route *Route) {
+				if route == nil || route.DisableMetrics {
+					t.Errorf("route = %+v, want it unchanged", route)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewProvider(config.Default())
+			tt.rule.Name = "r"
+			p.policy = newTestPolicy(t, Policy{Routes: RoutePolicy{Rules: []RouteRule{tt.rule}}})
+			labels := map[string]string{"goma.enable": "true"}
+			for key, value := range tt.labels {
+				labels[key] = value
+			}
+			parsed := parseTestContainer(p, "web", labels)
+			var route *Route
+			if len(parsed.Routes) == 1 {
+				route = &parsed.Routes[0]
+			}
+			tt.check(t, route)
+
+			if tt.severity == "" {
+				if len(p.diagnostics) != 0 {
+					t.Errorf("diagnostics = %v, want none", p.diagnostics)
+				}
+			} else if len(p.diagnostics) != 1 || p.diagnostics[0].Severity != tt.severity {
+				t.Errorf("diagnostics = %v, want one %s", p.diagnostics, tt.severity)
+			}
+		})
+	}
+}
+
+func TestRoutePolicyDecisionsGauge(t *testing.T) {
+	web := container.Summary{ID: "web-id", Names: []string{"/web"}, State: "running", Labels: map[string]string{"goma.enable": "true"}}
+	p := newSyncTestProvider(t, web)
+	p.policy = newTestPolicy(t, Policy{Routes: RoutePolicy{Rules: []RouteRule{
+		{Name: "waf", Middlewares: []string{"waf"}},
+	}}})
+
+	for range 3 {
+		if err := p.syncConfiguration(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var out strings.Builder
+	p.metrics.write(&out)
+	if metric := `goma_provider_route_policy_decisions{rule="waf",action="append"} 1`; !strings.Contains(out.String(), metric) {
+		t.Errorf("metrics do not contain %q:\n%s", metric, out.String())
+	}
+}
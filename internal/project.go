@@ -0,0 +1,190 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jkaninda/goma-docker-provider/internal/config"
+)
+
+// projectDefaultsLabel marks a container or service whose goma.* labels are
+// the defaults of every route of its compose project or Swarm stack.
+const projectDefaultsLabel = "goma.project_defaults"
+
+// projectDefaults are the goma.* labels declared by a goma.project_defaults
+// container or service.
+type projectDefaults struct {
+	source  string
+	project string
+	labels  map[string]string
+}
+
+// projectName returns the compose project or Swarm stack of a route owner.
+func (o routeOwner) projectName() string {
+	if o.project != "" {
+		return o.project
+	}
+	return o.stack
+}
+
+// inheritedLabel reports whether a label can be set by project defaults.
+// Enabling discovery, route names, canaries and named routes stay per container.
+func inheritedLabel(key string) bool {
+	if !strings.HasPrefix(key, "goma.") {
+		return false
+	}
+	switch key {
+	case "goma.enable", "goma.name", "goma.canary.of", projectDefaultsLabel:
+		return false
+	}
+	return !namedRoutePattern.MatchString(key)
+}
+
+// declaredProjectDefaults reads the defaults declared by the labels of a
+// goma.project_defaults container or service.
+func (p *Provider) declaredProjectDefaults(source string, labels map[string]string) (projectDefaults, bool) {
+	if labels[projectDefaultsLabel] != "true" {
+		return projectDefaults{}, false
+	}
+	project := containerOwner(labels, "").projectName()
+	if project == "" {
+		p.warnf(source, projectDefaultsLabel, "ignored because the container is not part of a compose project or stack")
+		return projectDefaults{}, false
+	}
+	if labels["goma.enable"] != "true" {
+		// Labels of enabled containers are also checked as routes
+		p.checkLabels(source, labels)
+		keys := make([]string, 0, len(labels))
+		for key := range labels {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if strings.HasPrefix(key, "goma.") && !inheritedLabel(key) && key != projectDefaultsLabel {
+				p.warnf(source, key, "not inherited by the routes of project %q", project)
+			}
+		}
+	}
+	resolved, ok := p.resolveLabels(source, containerOwner(labels, ""), labels)
+	if !ok {
+		return projectDefaults{}, false
+	}
+	return projectDefaults{source: source, project: project, labels: resolved}, true
+}
+
+// setProjectDefaults merges the project defaults of the configuration with
+// the ones declared by goma.project_defaults containers or services, which
+// take precedence.
+func (p *Provider) setProjectDefaults(sources []labeledSource) {
+	declared := make([]projectDefaults, 0, len(sources))
+	for _, source := range sources {
+		if defaults, ok := p.declaredProjectDefaults(source.name, source.labels); ok {
+			declared = append(declared, defaults)
+		}
+	}
+
+	p.projectDefaults = make(map[string]map[string]fieldDefault)
+	for project, labels := range p.config.ProjectDefaults {
+		resolved, ok := p.resolveConfigLabels(labels)
+		if !ok {
+			continue
+		}
+		p.addProjectDefaults(project, "config", resolved)
+	}
+
+	sort.Slice(declared, func(i, j int) bool {
+		return declared[i].source < declared[j].source
+	})
+	declaredBy := make(map[string]string)
+	for _, defaults := range declared {
+		if previous, ok := declaredBy[defaults.project]; ok {
+			p.warnf(defaults.source, projectDefaultsLabel, "project %q defaults are also declared by %s, merging them in name order", defaults.project, previous)
+		}
+		declaredBy[defaults.project] = defaults.source
+		p.addProjectDefaults(defaults.project, defaults.source, defaults.labels)
+	}
+}
+
+func (p *Provider) addProjectDefaults(project, source string, labels map[string]string) {
+	defaults := p.projectDefaults[project]
+	if defaults == nil {
+		defaults = make(map[string]fieldDefault)
+		p.projectDefaults[project] = defaults
+	}
+	for key, value := range labels {
+		if inheritedLabel(key) {
+			defaults[strings.TrimPrefix(key, "goma.")] = fieldDefault{
+				value:  value,
+				source: fmt.Sprintf("%s (%s)", sourceProject, source),
+			}
+		}
+	}
+}
+
+// setGlobalDefaults resolves the route defaults of the configuration.
+func (p *Provider) setGlobalDefaults() {
+	p.globalDefaults = make(map[string]fieldDefault)
+	resolved, ok := p.resolveConfigLabels(p.config.RouteDefaults)
+	if !ok {
+		return
+	}
+	for key, value := range resolved {
+		if inheritedLabel(key) {
+			p.globalDefaults[strings.TrimPrefix(key, "goma.")] = fieldDefault{value: value, source: sourceGlobal}
+		}
+	}
+}
+
+// checkConfigDefaults validates the route and project defaults of the
+// configuration.
+func checkConfigDefaults(cfg *config.Config) error {
+	errs := checkDefaults("routeDefaults", cfg.RouteDefaults)
+	projects := make([]string, 0, len(cfg.ProjectDefaults))
+	for project := range cfg.ProjectDefaults {
+		projects = append(projects, project)
+	}
+	sort.Strings(projects)
+	for _, project := range projects {
+		errs = append(errs, checkDefaults(fmt.Sprintf("projectDefaults[%s]", project), cfg.ProjectDefaults[project])...)
+	}
+	return errors.Join(errs...)
+}
+
+// routeName prefixes a route name with its project when routes are grouped
+// by project. Names already starting with the project are kept, as are the
+// default names of compose containers and stack services.
+func (p *Provider) routeName(name, project string) string {
+	if !p.config.GroupByProject || project == "" {
+		return name
+	}
+	if strings.HasPrefix(name, project+"-") || strings.HasPrefix(name, project+"_") {
+		return name
+	}
+	return project + "-" + name
+}
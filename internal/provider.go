@@ -27,8 +27,12 @@ package internal
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
 	"time"
 
+	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/docker/docker/client"
 	"github.com/jkaninda/goma-docker-provider/internal/config"
@@ -37,65 +41,253 @@ import (
 
 type Provider struct {
 	config       *config.Config
-	dockerClient *client.Client
+	dockerClient dockerAPI
 	lastHash     string
 	isSwarmMode  bool
+	isPodman     bool
 	ticker       *time.Ticker
+	// diagnostics collects label problems found during the current sync
+	diagnostics         []Diagnostic
+	lastDiagnosticsHash string
+	// elector is nil when leader election is disabled
+	elector *leaderElector
+	// notifier is nil when no webhook is configured
+	notifier *notifier
+	// lastRoutes are the routes of the last written configuration
+	lastRoutes []Route
+	// policy is nil when no policy file is configured
+	policy *Policy
+	// entryPoints are the known gateway entry points, nil when they are not checked
+	entryPoints []string
+	// entryPointAddresses are the listen addresses of the known entry points, by name
+	entryPointAddresses map[string]string
+	metrics             *metrics
+	// targetHosts maps containers sharing another container's network
+	// namespace to the name of that container
+	targetHosts map[string]string
+	// addresses resolves the containers or services referenced by labels
+	addresses addressIndex
+	// projectDefaults are the goma.* labels applied to the routes of each
+	// compose project or Swarm stack
+	projectDefaults map[string]map[string]fieldDefault
+	// globalDefaults are the route defaults of the configuration
+	globalDefaults map[string]fieldDefault
+	// traffic holds the traffic settings of routes, by route name
+	traffic map[string]trafficSettings
+	// containerRoutes are the routes of each container at the last sync,
+	// by container ID
+	containerRoutes map[string][]Route
+	// draining holds the stopping containers, by container ID
+	draining map[string]*drainingContainer
+	// resolveReferences is false when validating offline
+	resolveReferences bool
+	// secrets are the reference values resolved by the current sync, masked
+	// in diagnostics and notifications
+	secrets map[string]bool
+	// lastSecrets are the reference values of lastRoutes
+	lastSecrets map[string]bool
+	status      Status
+	statusMu    sync.RWMutex
 }
 
-func NewProvider() *Provider {
-	return &Provider{config: config.New()}
+func NewProvider(cfg *config.Config) *Provider {
+	return &Provider{config: cfg, metrics: newMetrics(), resolveReferences: true}
 }
 
 func (p *Provider) Start(ctx context.Context) error {
-	var err error
+	if err := p.connect(ctx); err != nil {
+		return err
+	}
+	defer p.closeClient()
 
-	p.dockerClient, err = client.NewClientWithOpts(
-		client.FromEnv,
-		client.WithAPIVersionNegotiation(),
-	)
-	if err != nil {
-		return fmt.Errorf("failed to create Docker client: %w", err)
+	if p.config.StatusAddr != "" {
+		go p.serveStatus(ctx)
 	}
-	defer func() {
-		if err := p.dockerClient.Close(); err != nil {
-			logger.Error("failed to close docker client", "error", err)
-		}
-	}()
 
-	info, err := p.dockerClient.Info(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to get Docker info: %w", err)
+	if len(p.config.Webhooks) > 0 {
+		p.notifier = newNotifier(p.config.Webhooks)
+		go p.notifier.run(ctx)
 	}
 
-	p.isSwarmMode = info.Swarm.LocalNodeState == swarm.LocalNodeStateActive
-	if p.isSwarmMode {
-		logger.Info("Docker Swarm mode detected")
-	} else {
-		logger.Info("Standalone Docker mode detected")
+	var leaderChanges <-chan bool
+	if p.config.LeaderElection {
+		p.elector = newLeaderElector(p.config.OutputDir, p.config.Identity, p.config.LeaseDuration)
+		p.elector.tryAcquireOrRenew()
+		leaderChanges = p.elector.changes
+		go p.elector.run(ctx)
+		if !p.elector.IsLeader() {
+			logger.Info("Leader election enabled, starting as standby", "identity", p.config.Identity)
+		}
 	}
 
 	// Initial sync
-	if err := p.syncConfiguration(ctx); err != nil {
+	if err := p.sync(ctx); err != nil {
 		return fmt.Errorf("initial sync failed: %w", err)
 	}
 
 	p.ticker = time.NewTicker(p.config.PollInterval)
 	defer p.ticker.Stop()
 
+	// Container events trigger a sync without waiting for the next poll
+	containerEvents := make(chan events.Message)
+	if !p.config.EnableSwarm || !p.isSwarmMode {
+		for _, label := range p.enableLabels() {
+			go p.watchEvents(ctx, containerEvents, label)
+		}
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			logger.Info("Provider context cancelled, stopping")
 			return ctx.Err()
 
+		case leader := <-leaderChanges:
+			if !leader {
+				continue
+			}
+			// Another replica may have written since our last sync
+			p.lastHash = ""
+			if err := p.sync(ctx); err != nil {
+				logger.Error("Failed to sync configuration", "error", err)
+			}
+
+		case message := <-containerEvents:
+			p.handleEvent(message)
+			if err := p.sync(ctx); err != nil {
+				logger.Error("Failed to sync configuration", "error", err)
+			}
+
 		case <-p.ticker.C:
-			if err := p.syncConfiguration(ctx); err != nil {
+			if err := p.sync(ctx); err != nil {
 				logger.Error("Failed to sync configuration", "error", err)
 			}
 		}
 	}
 }
+
+// sync runs a sync pass, unless this replica is a standby.
+func (p *Provider) sync(ctx context.Context) error {
+	if p.elector != nil && !p.elector.IsLeader() {
+		return nil
+	}
+	p.metrics.syncs.inc()
+	if err := p.syncConfiguration(ctx); err != nil {
+		p.metrics.syncErrors.inc()
+		return err
+	}
+	return nil
+}
+
 func (p *Provider) Stop() error {
 	return nil
 }
+
+// Render runs a single discovery pass and returns the generated configuration
+// in the given format, without writing it.
+func (p *Provider) Render(ctx context.Context, format string) ([]byte, error) {
+	if err := p.connect(ctx); err != nil {
+		return nil, err
+	}
+	defer p.closeClient()
+
+	config, err := p.buildConfiguration(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.logDiagnostics()
+	logFieldSources(config.Routes)
+
+	return MarshalConfiguration(config, format, p.config.TargetVersion)
+}
+
+// CurrentConfiguration returns the content of the generated file in the output directory.
+func (p *Provider) CurrentConfiguration() ([]byte, error) {
+	return os.ReadFile(p.OutputFile())
+}
+
+// OutputFile returns the path of the generated file.
+func (p *Provider) OutputFile() string {
+	return filepath.Join(p.config.OutputDir, outputFile)
+}
+
+// connect loads the policy, creates the Docker client and detects Swarm mode.
+func (p *Provider) connect(ctx context.Context) error {
+	var err error
+
+	if err := checkConfigDefaults(p.config); err != nil {
+		return fmt.Errorf("invalid route defaults: %w", err)
+	}
+
+	if p.config.PolicyFile != "" {
+		if p.policy, err = LoadPolicy(p.config.PolicyFile); err != nil {
+			return err
+		}
+		logger.Info("Policy loaded", "file", p.config.PolicyFile, "host_rules", len(p.policy.Hosts.Rules), "route_rules", len(p.policy.Routes.Rules))
+	}
+	if p.entryPoints, p.entryPointAddresses, err = loadEntryPoints(p.config); err != nil {
+		return err
+	}
+	if p.entryPoints != nil {
+		logger.Info("Entry points loaded", "entry_points", p.entryPoints)
+	}
+
+	opts := []client.Opt{
+		client.FromEnv,
+		client.WithAPIVersionNegotiation(),
+	}
+	host := p.config.DockerHost
+	if host == "" {
+		host = detectSocket()
+	}
+	if host != "" {
+		opts = append(opts, client.WithHost(host))
+	}
+	dockerClient, err := client.NewClientWithOpts(opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create Docker client: %w", err)
+	}
+	p.dockerClient = dockerClient
+	logger.Debug("Docker client created", "host", dockerClient.DaemonHost())
+
+	if err := p.detectEngine(ctx); err != nil {
+		p.closeClient()
+		return err
+	}
+	return nil
+}
+
+// detectEngine detects whether the engine is Podman and whether Swarm mode is active.
+func (p *Provider) detectEngine(ctx context.Context) error {
+	info, err := p.dockerClient.Info(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get Docker info: %w", err)
+	}
+
+	if version, err := p.dockerClient.ServerVersion(ctx); err == nil {
+		p.isPodman = isPodman(version)
+	} else {
+		logger.Debug("Failed to get server version", "error", err)
+	}
+
+	p.isSwarmMode = info.Swarm.LocalNodeState == swarm.LocalNodeStateActive
+	p.updateStatus(func(status *Status) { status.SwarmMode = p.isSwarmMode })
+	switch {
+	case p.isPodman:
+		logger.Info("Podman detected")
+		if p.config.EnableSwarm {
+			logger.Warn("Swarm mode is not supported by Podman, discovering containers")
+		}
+	case p.isSwarmMode:
+		logger.Info("Docker Swarm mode detected")
+	default:
+		logger.Info("Standalone Docker mode detected")
+	}
+	return nil
+}
+
+func (p *Provider) closeClient() {
+	if err := p.dockerClient.Close(); err != nil {
+		logger.Error("failed to close docker client", "error", err)
+	}
+}
@@ -0,0 +1,209 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// schemaID identifies the published label schema.
+const schemaID = "https://github.com/jkaninda/goma-docker-provider/schema/labels.json"
+
+// kindPatterns validate label values given as strings, as in the list form of compose labels.
+var kindPatterns = map[fieldKind]string{
+	kindInteger:  `^-?[0-9]+$`,
+	kindBoolean:  `^(1|t|T|TRUE|true|True|0|f|F|FALSE|false|False)$`,
+	kindDuration: `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`,
+	kindIntList:  `^\s*-?[0-9]+(\s*,\s*-?[0-9]+)*\s*$`,
+}
+
+// labelSchema collects the label properties and key patterns of the schema.
+type labelSchema struct {
+	properties        map[string]any
+	patternProperties map[string]any
+}
+
+func (s *labelSchema) property(key string, field routeField, named bool) {
+	s.properties[key] = valueSchema(field, named)
+}
+
+func (s *labelSchema) pattern(pattern string, field routeField, named bool) {
+	s.patternProperties[pattern] = valueSchema(field, named)
+}
+
+// LabelSchema returns the JSON Schema of the goma.* labels of a container or
+// service, in the map form of compose labels. It is generated from the
+// registries the parser uses.
+func LabelSchema() map[string]any {
+	s := labelSchema{properties: make(map[string]any), patternProperties: make(map[string]any)}
+	for _, field := range providerLabels {
+		s.property(field.key, field, false)
+	}
+	for _, field := range trafficFields {
+		s.pattern(`^goma\.traffic\..+\.`+regexp.QuoteMeta(field.key)+`$`, field, false)
+	}
+	for _, field := range routeFieldRegistry {
+		if prefix, wildcard := strings.CutSuffix(field.key, "*"); wildcard {
+			s.pattern(`^goma\.`+regexp.QuoteMeta(prefix)+`.+$`, field, true)
+			s.pattern(`^goma\.routes\.[^.]+\.`+regexp.QuoteMeta(prefix)+`.+$`, field, true)
+			continue
+		}
+		s.property("goma."+field.key, field, true)
+		s.pattern(`^goma\.routes\.[^.]+\.`+regexp.QuoteMeta(field.key)+`$`, field, true)
+	}
+	for _, protocol := range []string{ProtocolTCP, ProtocolUDP} {
+		for _, field := range l4Fields[protocol] {
+			s.property("goma."+protocol+"."+field.key, field, true)
+			s.pattern(`^goma\.`+protocol+`\.[^.]+\.`+regexp.QuoteMeta(field.key)+`$`, field, true)
+		}
+	}
+
+	return map[string]any{
+		"$schema":           "https://json-schema.org/draft/2020-12/schema",
+		"$id":               schemaID,
+		"title":             "Goma Docker provider labels",
+		"description":       "goma.* labels of a container or Swarm service. Other labels are allowed.",
+		"type":              "object",
+		"properties":        s.properties,
+		"patternProperties": s.patternProperties,
+		"propertyNames":     map[string]any{"anyOf": s.keySchemas()},
+	}
+}
+
+// keySchemas returns the schemas of the allowed label keys: any key outside
+// of the goma.* namespace, and the known goma.* keys, so typos are reported.
+func (s *labelSchema) keySchemas() []any {
+	keys := make([]string, 0, len(s.properties))
+	for key := range s.properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	schemas := []any{
+		map[string]any{"not": map[string]any{"pattern": `^goma\.`}},
+		map[string]any{"enum": keys},
+	}
+	for _, pattern := range s.patterns() {
+		schemas = append(schemas, map[string]any{"pattern": pattern})
+	}
+	return schemas
+}
+
+func (s *labelSchema) patterns() []string {
+	patterns := make([]string, 0, len(s.patternProperties))
+	for pattern := range s.patternProperties {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	return patterns
+}
+
+// valueSchema returns the schema of the value of a label. Values are strings
+// in compose files, typed YAML scalars and ${...} references are accepted too.
+func valueSchema(field routeField, named bool) map[string]any {
+	typed := map[string]any{"type": "string"}
+	switch field.kind {
+	case kindInteger:
+		typed["type"] = []string{"string", "integer"}
+	case kindBoolean:
+		typed["type"] = []string{"string", "boolean"}
+	}
+	if pattern, ok := kindPatterns[field.kind]; ok {
+		typed["pattern"] = pattern
+	}
+	if len(field.values) > 0 {
+		typed["enum"] = field.values
+	}
+	schema := map[string]any{
+		"anyOf": []any{
+			typed,
+			map[string]any{"type": "string", "pattern": referencePattern.String()},
+		},
+		"description":   field.description,
+		"x-kind":        string(field.kind),
+		"x-named-route": named,
+	}
+	if field.builtin != "" {
+		schema["default"] = field.builtin
+	}
+	return schema
+}
+
+// ComposeSchema returns a JSON Schema checking the goma.* labels of the
+// services of a compose or stack file, in the map and list forms.
+func ComposeSchema() map[string]any {
+	labels := LabelSchema()
+	s := labelSchema{properties: labels["properties"].(map[string]any), patternProperties: labels["patternProperties"].(map[string]any)}
+
+	// In the list form, only the keys can be checked
+	keys := make([]string, 0, len(s.properties))
+	for key := range s.properties {
+		keys = append(keys, regexp.QuoteMeta(key))
+	}
+	sort.Strings(keys)
+	for _, pattern := range s.patterns() {
+		keys = append(keys, strings.TrimSuffix(strings.TrimPrefix(pattern, "^"), "$"))
+	}
+	listItem := map[string]any{
+		"type": "string",
+		"anyOf": []any{
+			map[string]any{"not": map[string]any{"pattern": `^goma\.`}},
+			map[string]any{"pattern": `^(` + strings.Join(keys, "|") + `)(=|$)`},
+		},
+	}
+	delete(labels, "$schema")
+	delete(labels, "$id")
+	labelsRef := map[string]any{
+		"oneOf": []any{
+			map[string]any{"$ref": "#/$defs/labels"},
+			map[string]any{"type": "array", "items": listItem},
+		},
+	}
+
+	return map[string]any{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"$id":         strings.Replace(schemaID, "labels.json", "compose.json", 1),
+		"title":       "Goma Docker provider labels of a compose file",
+		"description": "Checks the goma.* labels and deploy labels of the services, any other key is allowed.",
+		"type":        "object",
+		"properties": map[string]any{
+			"services": map[string]any{
+				"type": "object",
+				"additionalProperties": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"labels": labelsRef,
+						"deploy": map[string]any{
+							"type":       "object",
+							"properties": map[string]any{"labels": labelsRef},
+						},
+					},
+				},
+			},
+		},
+		"$defs": map[string]any{"labels": labels},
+	}
+}
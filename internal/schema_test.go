@@ -0,0 +1,121 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/jkaninda/goma-docker-provider/internal/config"
+)
+
+// schemaAccepts reports whether the label schema declares a key.
+func schemaAccepts(schema map[string]any, key string) bool {
+	if _, ok := schema["properties"].(map[string]any)[key]; ok {
+		return true
+	}
+	for pattern := range schema["patternProperties"].(map[string]any) {
+		if regexp.MustCompile(pattern).MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// parserKeys returns a label key for every label the parser accepts.
+func parserKeys() []string {
+	keys := make([]string, 0)
+	for _, field := range providerLabels {
+		keys = append(keys, field.key)
+	}
+	for _, field := range routeFieldRegistry {
+		key := strings.Replace(field.key, "*", "X-Test", 1)
+		keys = append(keys, "goma."+key, "goma.routes.api."+key)
+	}
+	for protocol, fields := range l4Fields {
+		for _, field := range fields {
+			keys = append(keys, "goma."+protocol+"."+field.key, "goma."+protocol+".db."+field.key)
+		}
+	}
+	return keys
+}
+
+func TestLabelSchemaCoversParser(t *testing.T) {
+	schema := LabelSchema()
+	p := NewProvider(config.Default())
+	for _, key := range parserKeys() {
+		if !schemaAccepts(schema, key) {
+			t.Errorf("label %s is missing from the schema", key)
+		}
+		labels := map[string]string{key: "1"}
+		p.diagnostics = nil
+		p.checkLabels("web", labels)
+		for _, d := range p.diagnostics {
+			if strings.Contains(d.Message, "unknown label") {
+				t.Errorf("label %s is unknown to the parser: %v", key, d)
+			}
+		}
+	}
+
+	// Every setting of controllers is parsed
+	for _, field := range trafficFields {
+		key := "goma.traffic.web." + field.key
+		if !schemaAccepts(schema, key) {
+			t.Errorf("label %s is missing from the schema", key)
+		}
+		value := "10"
+		if len(field.values) > 0 {
+			value = field.values[0]
+		}
+		p.diagnostics = nil
+		p.setTraffic([]labeledSource{{name: "controller", labels: map[string]string{key: value}}})
+		if len(p.diagnostics) != 0 {
+			t.Errorf("label %s is not parsed: %v", key, p.diagnostics)
+		}
+	}
+}
+
+func TestLabelSchemaRejectsUnknownLabels(t *testing.T) {
+	schema := LabelSchema()
+	for _, key := range []string{"goma.hots", "goma.routes.api.hots", "goma.tcp.db.sni_names", "goma.traffic.web.weight"} {
+		if schemaAccepts(schema, key) {
+			t.Errorf("schema accepts unknown label %s", key)
+		}
+	}
+}
+
+func TestCheckProviderLabels(t *testing.T) {
+	p := NewProvider(config.Default())
+	p.checkLabels("web", map[string]string{
+		"goma.enable":      "yes please",
+		drainPeriodLabel:   "soon",
+		controllerLabel:    "true",
+		"goma.traffic.x.y": "ignored",
+	})
+	if len(p.diagnostics) != 2 || !hasErrors(p.diagnostics) {
+		t.Errorf("diagnostics = %v, want two invalid values", p.diagnostics)
+	}
+}
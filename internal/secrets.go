@@ -0,0 +1,353 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const redactedValue = "******"
+
+// referencePattern matches ${kind:name} references in label values
+var referencePattern = regexp.MustCompile(`\$\{([a-z]+):([^}]*)\}`)
+
+// resolveLabels replaces the ${env:NAME}, ${file:/path} and ${secret:name}
+// references in the goma.* labels of a container or service. It reports
+// whether all references could be resolved, the routes of a source with
+// unresolved references are skipped.
+//
+// Sources may only read the environment variables starting with the
+// reference prefix and the secrets allowed for their project.
+func (p *Provider) resolveLabels(source string, owner routeOwner, labels map[string]string) (map[string]string, bool) {
+	return p.resolveReferenceLabels(source, labels, func(kind, name string) error {
+		return p.allowReference(owner, kind, name)
+	})
+}
+
+// resolveConfigLabels replaces the references in the labels of the provider
+// configuration, which may read any variable or secret.
+func (p *Provider) resolveConfigLabels(labels map[string]string) (map[string]string, bool) {
+	return p.resolveReferenceLabels("config", labels, func(string, string) error {
+		return nil
+	})
+}
+
+// resolveReferenceLabels replaces the references allowed by allow.
+//
+// Resolved values are recorded so they can be masked in diagnostics and
+// notifications, they are only ever written to the generated file.
+func (p *Provider) resolveReferenceLabels(source string, labels map[string]string, allow func(kind, name string) error) (map[string]string, bool) {
+	resolved := make(map[string]string, len(labels))
+	ok := true
+	for key, value := range labels {
+		if !strings.HasPrefix(key, "goma.") || !strings.Contains(value, "${") {
+			resolved[key] = value
+			continue
+		}
+		resolved[key] = referencePattern.ReplaceAllStringFunc(value, func(ref string) string {
+			match := referencePattern.FindStringSubmatch(ref)
+			kind, name := match[1], match[2]
+			var secret string
+			err := allow(kind, name)
+			if err == nil {
+				secret, err = p.resolveReference(kind, name)
+			}
+			if err != nil {
+				p.errorf(source, key, "cannot resolve %s: %v", ref, err)
+				ok = false
+				return ref
+			}
+			if !p.resolveReferences {
+				return ref
+			}
+			p.addSecret(secret)
+			return secret
+		})
+	}
+	if !ok {
+		p.errorf(source, "", "routes skipped because of unresolved references")
+	}
+	return resolved, ok
+}
+
+// resolveReference returns the value of a reference. When references are not
+// resolved, as when validating offline, only their syntax is checked.
+func (p *Provider) resolveReference(kind, name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("empty %s reference", kind)
+	}
+	switch kind {
+	case "env":
+		if !p.resolveReferences {
+			return "", nil
+		}
+		value, exists := os.LookupEnv(name)
+		if !exists {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return value, nil
+	case "secret":
+		if strings.ContainsAny(name, `/\`) || name == ".." {
+			return "", fmt.Errorf("invalid secret name %q", name)
+		}
+		return p.readSecretFile(filepath.Join(p.config.SecretsDir, name))
+	case "file":
+		path := filepath.Clean(name)
+		dir := filepath.Clean(p.config.SecretsDir)
+		if !filepath.IsAbs(path) || !strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return "", fmt.Errorf("file %s is outside the secrets directory %s", name, dir)
+		}
+		return p.readSecretFile(path)
+	default:
+		return "", fmt.Errorf("unsupported reference type %q, expected env, file or secret", kind)
+	}
+}
+
+// allowReference checks that a source may read a reference: environment
+// variables must start with the reference prefix, and secrets must be
+// listed for the project of the source or for every project. Invalid
+// references are reported by resolveReference, and nothing is checked when
+// validating offline, as the allowed references are part of the provider
+// configuration.
+func (p *Provider) allowReference(owner routeOwner, kind, name string) error {
+	if !p.resolveReferences {
+		return nil
+	}
+	switch kind {
+	case "env":
+		if !strings.HasPrefix(name, p.config.ReferenceEnvPrefix) {
+			return fmt.Errorf("environment variable %s does not start with the reference prefix %s", name, p.config.ReferenceEnvPrefix)
+		}
+	case "secret", "file":
+		if kind == "file" {
+			name, _ = filepath.Rel(p.config.SecretsDir, name)
+		}
+		if !filepath.IsLocal(name) {
+			return nil
+		}
+		project := owner.projectName()
+		if !slices.Contains(p.config.ProjectSecrets[project], name) && !slices.Contains(p.config.ProjectSecrets["*"], name) {
+			if project == "" {
+				return fmt.Errorf("secret %s is not allowed for sources outside a project", name)
+			}
+			return fmt.Errorf("secret %s is not allowed for project %s", name, project)
+		}
+	}
+	return nil
+}
+
+func (p *Provider) readSecretFile(path string) (string, error) {
+	if !p.resolveReferences {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s", path)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+func (p *Provider) addSecret(secret string) {
+	if secret == "" {
+		return
+	}
+	if p.secrets == nil {
+		p.secrets = make(map[string]bool)
+	}
+	p.secrets[secret] = true
+}
+
+// redact masks the resolved reference values found in s.
+func (p *Provider) redact(s string) string {
+	return redactSecrets(s, p.secrets)
+}
+
+// redactSecrets masks the secrets found in s.
+func redactSecrets(s string, secrets map[string]bool) string {
+	if len(secrets) == 0 {
+		return s
+	}
+	// Longest first, so a secret containing another one is fully masked
+	sorted := make([]string, 0, len(secrets))
+	for secret := range secrets {
+		sorted = append(sorted, secret)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return len(sorted[i]) > len(sorted[j])
+	})
+	for _, secret := range sorted {
+		s = strings.ReplaceAll(s, secret, redactedValue)
+	}
+	return s
+}
+
+// redactRoute returns a copy of the route with the secrets masked.
+func redactRoute(route *Route, secrets map[string]bool) *Route {
+	if route == nil || len(secrets) == 0 {
+		return route
+	}
+	redacted := *route
+	redactStrings(reflect.ValueOf(&redacted).Elem(), func(s string) string {
+		return redactSecrets(s, secrets)
+	})
+	return &redacted
+}
+
+// redactStrings applies redact to every exported string reachable from v,
+// copying slices and maps so the original route is left untouched.
+func redactStrings(v reflect.Value, redact func(string) string) {
+	switch v.Kind() {
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(redact(v.String()))
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				redactStrings(v.Field(i), redact)
+			}
+		}
+	case reflect.Pointer:
+		if !v.IsNil() && v.CanSet() {
+			copied := reflect.New(v.Elem().Type())
+			copied.Elem().Set(v.Elem())
+			redactStrings(copied.Elem(), redact)
+			v.Set(copied)
+		}
+	case reflect.Slice:
+		if !v.IsNil() && v.CanSet() {
+			copied := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+			reflect.Copy(copied, v)
+			for i := 0; i < copied.Len(); i++ {
+				redactStrings(copied.Index(i), redact)
+			}
+			v.Set(copied)
+		}
+	case reflect.Map:
+		if !v.IsNil() && v.CanSet() {
+			copied := reflect.MakeMapWithSize(v.Type(), v.Len())
+			for _, key := range v.MapKeys() {
+				value := reflect.New(v.Type().Elem()).Elem()
+				value.Set(v.MapIndex(key))
+				redactStrings(value, redact)
+				copied.SetMapIndex(key, value)
+			}
+			v.Set(copied)
+		}
+	case reflect.Interface:
+		if !v.IsNil() && v.CanSet() {
+			value := reflect.New(v.Elem().Type()).Elem()
+			value.Set(v.Elem())
+			redactStrings(value, redact)
+			v.Set(value)
+		}
+	}
+}
+
+// redactChanges masks resolved reference values in a change event. Routes
+// before the change are also masked with the values of the previous
+// configuration, which may have been rotated since.
+func (p *Provider) redactChanges(event ChangeEvent, previous map[string]bool) ChangeEvent {
+	before := maps.Clone(p.secrets)
+	if before == nil {
+		before = make(map[string]bool)
+	}
+	maps.Copy(before, previous)
+	for _, changes := range [][]RouteChange{event.Added, event.Removed, event.Modified} {
+		for i := range changes {
+			changes[i].Before = redactRoute(changes[i].Before, before)
+			changes[i].After = redactRoute(changes[i].After, p.secrets)
+		}
+	}
+	return event
+}
+
+// seedSecrets returns the values of the previous routes read from the
+// generated file that must be masked, as the reference values they were
+// generated with are not known after a restart. A value is masked when the
+// route of the same name holds a resolved reference value at the same place,
+// so that a value rotated while the provider was stopped is not leaked.
+func seedSecrets(previous, current []Route, secrets map[string]bool) map[string]bool {
+	seeded := make(map[string]bool)
+	if len(secrets) == 0 {
+		return seeded
+	}
+	byName := make(map[string]Route, len(current))
+	for _, route := range current {
+		byName[route.Name] = route
+	}
+	for _, route := range previous {
+		after, exists := byName[route.Name]
+		if !exists {
+			continue
+		}
+		pairStrings(reflect.ValueOf(route), reflect.ValueOf(after), func(before, after string) {
+			if before != "" && redactSecrets(after, secrets) != after {
+				seeded[before] = true
+			}
+		})
+	}
+	return seeded
+}
+
+// pairStrings calls fn with the exported strings found at the same place in
+// a and b, slices are paired by index and maps by key.
+func pairStrings(a, b reflect.Value, fn func(a, b string)) {
+	if a.Kind() != b.Kind() {
+		return
+	}
+	switch a.Kind() {
+	case reflect.String:
+		fn(a.String(), b.String())
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			if a.Type().Field(i).IsExported() {
+				pairStrings(a.Field(i), b.Field(i), fn)
+			}
+		}
+	case reflect.Pointer, reflect.Interface:
+		if !a.IsNil() && !b.IsNil() {
+			pairStrings(a.Elem(), b.Elem(), fn)
+		}
+	case reflect.Slice:
+		for i := 0; i < min(a.Len(), b.Len()); i++ {
+			pairStrings(a.Index(i), b.Index(i), fn)
+		}
+	case reflect.Map:
+		for _, key := range a.MapKeys() {
+			if value := b.MapIndex(key); value.IsValid() {
+				pairStrings(a.MapIndex(key), value, fn)
+			}
+		}
+	}
+}
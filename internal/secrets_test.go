@@ -0,0 +1,194 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/jkaninda/goma-docker-provider/internal/config"
+)
+
+func newSecretsProvider(t *testing.T) *Provider {
+	t.Helper()
+	dir := t.TempDir()
+	for name, value := range map[string]string{"shop_token": "s3cret\n", "shared": "sh4red", "blog_token": "bl0g"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(value), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := config.Default()
+	cfg.SecretsDir = dir
+	cfg.ProjectSecrets = map[string][]string{
+		"shop": {"shop_token"},
+		"*":    {"shared"},
+	}
+	return NewProvider(cfg)
+}
+
+func TestResolveLabelsAllowlist(t *testing.T) {
+	t.Setenv("GOMA_REF_PREFIX", "api")
+	t.Setenv("GOMA_WEBHOOK_SECRET", "hook")
+	shop := routeOwner{project: "shop"}
+	tests := []struct {
+		name  string
+		owner routeOwner
+		value string
+		want  string
+		error string
+	}{
+		{"env with prefix", shop, "/${env:GOMA_REF_PREFIX}", "/api", ""},
+		{"env without prefix", shop, "${env:GOMA_WEBHOOK_SECRET}", "", "does not start with the reference prefix GOMA_REF_"},
+		{"secret of the project", shop, "${secret:shop_token}", "s3cret", ""},
+		{"secret of another project", shop, "${secret:blog_token}", "", "secret blog_token is not allowed for project shop"},
+		{"secret shared with every project", routeOwner{stack: "blog"}, "${secret:shared}", "sh4red", ""},
+		{"source outside a project", routeOwner{}, "${secret:shop_token}", "", "not allowed for sources outside a project"},
+		{"invalid secret name", shop, "${secret:../shop_token}", "", "invalid secret name"},
+		{"unknown kind", shop, "${vault:shop_token}", "", "unsupported reference type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newSecretsProvider(t)
+			labels, ok := p.resolveLabels("web-1", tt.owner, map[string]string{"goma.rewrite": tt.value})
+			if tt.error != "" {
+				if ok {
+					t.Fatalf("resolved %q to %q, want error %q", tt.value, labels["goma.rewrite"], tt.error)
+				}
+				if len(p.diagnostics) == 0 || !strings.Contains(p.diagnostics[0].Message, tt.error) {
+					t.Fatalf("diagnostics = %v, want %q", p.diagnostics, tt.error)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("unexpected diagnostics: %v", p.diagnostics)
+			}
+			if got := labels["goma.rewrite"]; got != tt.want {
+				t.Errorf("resolved %q to %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveFileReferenceAllowlist(t *testing.T) {
+	p := newSecretsProvider(t)
+	shop := routeOwner{project: "shop"}
+	allowed := "${file:" + filepath.Join(p.config.SecretsDir, "shop_token") + "}"
+	if labels, ok := p.resolveLabels("web-1", shop, map[string]string{"goma.rewrite": allowed}); !ok || labels["goma.rewrite"] != "s3cret" {
+		t.Errorf("file reference of the project: got %q, %v", labels["goma.rewrite"], p.diagnostics)
+	}
+	denied := "${file:" + filepath.Join(p.config.SecretsDir, "blog_token") + "}"
+	if _, ok := p.resolveLabels("web-1", shop, map[string]string{"goma.rewrite": denied}); ok {
+		t.Error("file reference of another project was resolved")
+	}
+	if _, ok := p.resolveLabels("web-1", shop, map[string]string{"goma.rewrite": "${file:/etc/passwd}"}); ok {
+		t.Error("file reference outside the secrets directory was resolved")
+	}
+}
+
+func TestResolveConfigLabelsUnrestricted(t *testing.T) {
+	t.Setenv("API_PREFIX", "api")
+	p := newSecretsProvider(t)
+	labels, ok := p.resolveConfigLabels(map[string]string{
+		"goma.rewrite":     "/${env:API_PREFIX}",
+		"goma.middlewares": "${secret:blog_token}",
+	})
+	if !ok {
+		t.Fatalf("unexpected diagnostics: %v", p.diagnostics)
+	}
+	if labels["goma.rewrite"] != "/api" || labels["goma.middlewares"] != "bl0g" {
+		t.Errorf("resolved labels = %v", labels)
+	}
+}
+
+func TestRedactChangesPreviousSecrets(t *testing.T) {
+	p := NewProvider(config.Default())
+	p.addSecret("new-token")
+	event := ChangeEvent{Modified: []RouteChange{{
+		Before: &Route{Name: "api", Rewrite: "/old-token"},
+		After:  &Route{Name: "api", Rewrite: "/new-token"},
+	}}}
+	event = p.redactChanges(event, map[string]bool{"old-token": true})
+	change := event.Modified[0]
+	if change.Before.Rewrite != "/"+redactedValue || change.After.Rewrite != "/"+redactedValue {
+		t.Errorf("before = %q, after = %q", change.Before.Rewrite, change.After.Rewrite)
+	}
+}
+
+func TestSeedSecrets(t *testing.T) {
+	previous := []Route{
+		{Name: "api", Rewrite: "/old-token", Hosts: []string{"api.example.com"}},
+		{Name: "web", Rewrite: "/web"},
+	}
+	current := []Route{
+		{Name: "api", Rewrite: "/new-token", Hosts: []string{"api.example.com"}},
+		{Name: "web", Rewrite: "/web"},
+	}
+	seeded := seedSecrets(previous, current, map[string]bool{"new-token": true})
+	if len(seeded) != 1 || !seeded["/old-token"] {
+		t.Errorf("seedSecrets() = %v, want the previous value of the rotated reference", seeded)
+	}
+	if seeded := seedSecrets(previous, current, nil); len(seeded) != 0 {
+		t.Errorf("seedSecrets() = %v, want nothing without resolved references", seeded)
+	}
+}
+
+func TestNotificationAfterRestartMasksPreviousSecrets(t *testing.T) {
+	labels := map[string]string{
+		"goma.enable":  "true",
+		"goma.rewrite": "/${env:GOMA_REF_TOKEN}",
+	}
+	api := container.Summary{ID: "api-id", Names: []string{"/api"}, State: "running", Labels: labels}
+	old := container.Summary{ID: "old-id", Names: []string{"/old"}, State: "running", Labels: labels}
+
+	t.Setenv("GOMA_REF_TOKEN", "old-token")
+	p := newSyncTestProvider(t, api, old)
+	if err := p.syncConfiguration(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Restart with a rotated value, the old container was removed meanwhile
+	t.Setenv("GOMA_REF_TOKEN", "new-token")
+	restarted := NewProvider(p.config)
+	restarted.dockerClient = &fakeDocker{containers: []container.Summary{api}}
+	restarted.notifier = newNotifier(nil)
+	if err := restarted.syncConfiguration(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	event := <-restarted.notifier.queue
+	if len(event.Modified) != 1 || len(event.Removed) != 1 {
+		t.Fatalf("event = %+v, want the api route modified and the old route removed", event)
+	}
+	if data, _ := json.Marshal(event); strings.Contains(string(data), "token") {
+		t.Errorf("notification leaks a reference value: %s", data)
+	}
+	if event.Removed[0].Before != nil {
+		t.Errorf("removed route = %+v, want no previous value", event.Removed[0].Before)
+	}
+}
@@ -0,0 +1,103 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/jkaninda/logger"
+)
+
+// Status is the provider state reported by the status endpoint.
+type Status struct {
+	Identity    string    `json:"identity,omitempty"`
+	Role        string    `json:"role"`
+	SwarmMode   bool      `json:"swarmMode"`
+	LastSync    time.Time `json:"lastSync,omitempty"`
+	LastUpdate  time.Time `json:"lastUpdate,omitempty"`
+	ConfigHash  string    `json:"configHash,omitempty"`
+	Routes      int       `json:"routes"`
+	L4Routes    int       `json:"l4Routes"`
+	Diagnostics int       `json:"diagnostics"`
+	// Draining is the number of stopping containers whose routes are drained.
+	Draining int `json:"draining"`
+}
+
+// Status returns a snapshot of the provider state.
+func (p *Provider) Status() Status {
+	p.statusMu.RLock()
+	status := p.status
+	p.statusMu.RUnlock()
+
+	status.Identity = p.config.Identity
+	status.Role = RoleSingle
+	if p.elector != nil {
+		status.Role = RoleStandby
+		if p.elector.IsLeader() {
+			status.Role = RoleLeader
+		}
+	}
+	return status
+}
+
+func (p *Provider) updateStatus(update func(status *Status)) {
+	p.statusMu.Lock()
+	defer p.statusMu.Unlock()
+	update(&p.status)
+}
+
+// serveStatus serves the status endpoint until the context is cancelled.
+func (p *Provider) serveStatus(ctx context.Context) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(p.Status())
+	})
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		p.metrics.write(w)
+	})
+
+	server := &http.Server{
+		Addr:              p.config.StatusAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	logger.Info("Status endpoint listening", "addr", p.config.StatusAddr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("Status endpoint failed", "error", err)
+	}
+}
@@ -31,11 +31,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
@@ -51,34 +51,52 @@ var (
 	outputFile        = "goma-docker-provider.yaml"
 )
 
-func (p *Provider) syncConfiguration(ctx context.Context) error {
-	routes := make([]Route, 0)
+// Output formats
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+)
 
-	// Get routes from Swarm services
-	if p.config.EnableSwarm && p.isSwarmMode {
-		swarmRoutes, err := p.getSwarmRoutes(ctx)
-		if err != nil {
-			logger.Error("Failed to get Swarm routes", "error", err)
-			return err
-		} else {
-			routes = append(routes, swarmRoutes...)
-		}
-	} else {
-		// Get routes from containers
-		containerRoutes, err := p.getContainerRoutes(ctx)
-		if err != nil {
-			logger.Error("Failed to get container routes", "error", err)
-			return err
-		} else {
-			routes = append(routes, containerRoutes...)
+// fieldContext identifies where a set of route fields comes from, for diagnostics.
+type fieldContext struct {
+	source string
+	prefix string
+}
+
+func (fc fieldContext) key(field string) string {
+	return fc.prefix + field
+}
+
+// routeFields returns the labels starting with prefix, keyed by field name.
+func routeFields(labels map[string]string, prefix string) map[string]string {
+	fields := make(map[string]string)
+	for key, value := range labels {
+		if strings.HasPrefix(key, prefix) {
+			fields[strings.TrimPrefix(key, prefix)] = value
 		}
 	}
+	return fields
+}
 
-	sort.Slice(routes, func(i, j int) bool {
-		return routes[i].Name < routes[j].Name
-	})
+func (p *Provider) syncConfiguration(ctx context.Context) error {
+	if pinned, err := p.syncPinned(); pinned || err != nil {
+		return err
+	}
 
-	config := GomaConfig{Routes: routes}
+	config, err := p.buildConfiguration(ctx)
+	if err != nil {
+		return err
+	}
+
+	p.metrics.commitSync()
+	p.logDiagnostics()
+	p.updateStatus(func(status *Status) {
+		status.LastSync = time.Now()
+		status.Routes = len(config.Routes)
+		status.L4Routes = len(config.L4Routes)
+		status.Diagnostics = len(p.diagnostics)
+		status.Draining = len(p.draining)
+	})
 
 	// Generate hash
 	currentHash := p.calculateHash(config)
@@ -86,118 +104,331 @@ func (p *Provider) syncConfiguration(ctx context.Context) error {
 		return nil
 	}
 
+	previous, previousSecrets := p.lastRoutes, p.lastSecrets
+	fromDisk := p.lastHash == ""
+	if fromDisk {
+		// First write since start or since taking over, compare with the file on disk
+		previous = readRoutes(p.OutputFile())
+		previousSecrets = seedSecrets(previous, config.Routes, p.secrets)
+	}
+
 	// Write configuration
 	if err := p.writeConfiguration(config); err != nil {
 		return err
 	}
 
 	p.lastHash = currentHash
-	logger.Info("Goma Gateway routes configuration updated", "count", len(routes), "file", outputFile)
+	p.lastRoutes = config.Routes
+	p.lastSecrets = p.secrets
+	p.updateStatus(func(status *Status) {
+		status.LastUpdate = time.Now()
+		status.ConfigHash = currentHash
+	})
+
+	changes := diffRoutes(previous, config.Routes)
+	if fromDisk {
+		// The reference values of routes removed while stopped are unknown
+		for i := range changes.Removed {
+			changes.Removed[i].Before = nil
+		}
+	}
+	logger.Info("Goma Gateway routes configuration updated", "count", len(config.Routes), "file", outputFile,
+		"added", len(changes.Added), "removed", len(changes.Removed), "modified", len(changes.Modified))
+	logFieldSources(config.Routes)
+
+	if p.notifier != nil && !changes.Empty() {
+		changes.Timestamp = time.Now()
+		changes.Identity = p.config.Identity
+		changes.Hash = currentHash
+		p.notifier.notify(p.redactChanges(changes, previousSecrets))
+	}
 	return nil
 }
 
-func (p *Provider) getContainerRoutes(ctx context.Context) ([]Route, error) {
+// buildConfiguration discovers the labelled containers or services and builds
+// the gateway configuration, without writing it.
+func (p *Provider) buildConfiguration(ctx context.Context) (GomaConfig, error) {
+	p.diagnostics = nil
+	p.metrics.beginSync()
+	// Rebuilt on every sync, so rotated or removed values are forgotten
+	p.secrets = nil
+	p.setGlobalDefaults()
+
+	var config GomaConfig
+	var err error
+	if p.config.EnableSwarm && p.isSwarmMode {
+		// Get routes from Swarm services
+		if config, err = p.getSwarmRoutes(ctx); err != nil {
+			logger.Error("Failed to get Swarm routes", "error", err)
+			return GomaConfig{}, err
+		}
+	} else {
+		// Get routes from containers
+		if config, err = p.getContainerRoutes(ctx); err != nil {
+			logger.Error("Failed to get container routes", "error", err)
+			return GomaConfig{}, err
+		}
+	}
+
+	return p.finalizeConfiguration(config), nil
+}
+
+// finalizeConfiguration merges weighted routes, resolves conflicts and sorts the routes.
+func (p *Provider) finalizeConfiguration(config GomaConfig) GomaConfig {
+	routes := p.applyTraffic(config.Routes)
+	p.checkLoadBalancing(routes)
+	routes = p.resolveConflicts(routes)
+
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].Name < routes[j].Name
+	})
+	config.Routes, config.Middlewares = p.collectMiddlewares(routes)
+	config.L4Routes = p.finalizeL4Routes(config.L4Routes)
+	return p.checkTargetVersion(config)
+}
+
+// labeledSource is a container or service holding provider settings in its labels.
+type labeledSource struct {
+	name   string
+	labels map[string]string
+}
+
+// listContainerLabels returns the containers labeled label=true, stopped
+// ones included.
+func (p *Provider) listContainerLabels(ctx context.Context, label string) ([]labeledSource, error) {
 	containers, err := p.dockerClient.ContainerList(ctx, container.ListOptions{
+		All: true,
 		Filters: filters.NewArgs(
-			filters.Arg("label", "goma.enable=true"),
+			filters.Arg("label", label+"=true"),
 		),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to list containers: %w", err)
+		return nil, err
 	}
-
-	routes := make([]Route, 0)
+	sources := make([]labeledSource, 0, len(containers))
 	for _, container := range containers {
-		containerRoutes := p.parseContainerLabels(container)
-		if containerRoutes != nil {
-			routes = append(routes, containerRoutes...)
-		}
+		sources = append(sources, labeledSource{name: containerName(container), labels: container.Labels})
 	}
-
-	return routes, nil
+	return sources, nil
 }
 
-func (p *Provider) getSwarmRoutes(ctx context.Context) ([]Route, error) {
+// listServiceLabels returns the services labeled label=true.
+func (p *Provider) listServiceLabels(ctx context.Context, label string) ([]labeledSource, error) {
 	services, err := p.dockerClient.ServiceList(ctx, swarm.ServiceListOptions{
 		Filters: filters.NewArgs(
-			filters.Arg("label", "goma.enable=true"),
+			filters.Arg("label", label+"=true"),
 		),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to list services: %w", err)
+		return nil, err
 	}
-
-	routes := make([]Route, 0)
+	sources := make([]labeledSource, 0, len(services))
 	for _, service := range services {
-		serviceRoutes := p.parseServiceLabels(service)
-		if serviceRoutes != nil {
-			routes = append(routes, serviceRoutes...)
-		}
+		sources = append(sources, labeledSource{name: service.Spec.Name, labels: service.Spec.Labels})
 	}
+	return sources, nil
+}
 
-	return routes, nil
+// listEnabledContainers lists the containers with route discovery enabled.
+func (p *Provider) listEnabledContainers(ctx context.Context) ([]container.Summary, error) {
+	containers := make([]container.Summary, 0)
+	seen := make(map[string]bool)
+	for _, label := range p.enableLabels() {
+		list, err := p.dockerClient.ContainerList(ctx, container.ListOptions{
+			Filters: filters.NewArgs(
+				filters.Arg("label", label+"=true"),
+			),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list containers: %w", err)
+		}
+		for _, c := range list {
+			if !seen[c.ID] {
+				seen[c.ID] = true
+				containers = append(containers, c)
+			}
+		}
+	}
+	return containers, nil
 }
 
-func (p *Provider) parseContainerLabels(container container.Summary) []Route {
-	labels := container.Labels
+// listEnabledServices lists the Swarm services with route discovery enabled.
+func (p *Provider) listEnabledServices(ctx context.Context) ([]swarm.Service, error) {
+	services := make([]swarm.Service, 0)
+	seen := make(map[string]bool)
+	for _, label := range p.enableLabels() {
+		list, err := p.dockerClient.ServiceList(ctx, swarm.ServiceListOptions{
+			Filters: filters.NewArgs(
+				filters.Arg("label", label+"=true"),
+			),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list services: %w", err)
+		}
+		for _, service := range list {
+			if !seen[service.ID] {
+				seen[service.ID] = true
+				services = append(services, service)
+			}
+		}
+	}
+	return services, nil
+}
 
-	if labels["goma.enable"] != "true" {
-		return nil
+func (p *Provider) getContainerRoutes(ctx context.Context) (GomaConfig, error) {
+	containers, err := p.listEnabledContainers(ctx)
+	if err != nil {
+		return GomaConfig{}, err
 	}
 
-	containerName := container.Names[0][1:]
+	p.targetHosts = p.resolveTargetHosts(ctx, containers)
 
-	// Extract route names
-	routeNames := p.extractRouteNames(labels)
+	// Forward auth services do not need goma labels
+	running, err := p.dockerClient.ContainerList(ctx, container.ListOptions{})
+	if err != nil {
+		return GomaConfig{}, fmt.Errorf("failed to list containers: %w", err)
+	}
+	p.addresses = containerAddresses(running)
 
-	if len(routeNames) == 0 {
-		// single route mode
-		if route := p.parseSingleContainerRoute(labels, containerName); route != nil {
-			return []Route{*route}
-		}
-		return nil
+	// Defaults and controller containers only hold labels, they do not need to be running
+	defaults, err := p.listContainerLabels(ctx, projectDefaultsLabel)
+	if err != nil {
+		return GomaConfig{}, fmt.Errorf("failed to list project defaults containers: %w", err)
 	}
+	controllers, err := p.listContainerLabels(ctx, controllerLabel)
+	if err != nil {
+		return GomaConfig{}, fmt.Errorf("failed to list controller containers: %w", err)
+	}
+	p.setProjectDefaults(defaults)
+	p.setTraffic(controllers)
 
-	// Parse named routes
-	routes := make([]Route, 0, len(routeNames))
-	for _, routeName := range routeNames {
-		if route := p.parseNamedContainerRoute(labels, routeName, containerName); route != nil {
-			routes = append(routes, *route)
+	config := GomaConfig{Routes: make([]Route, 0)}
+	previous := p.containerRoutes
+	p.containerRoutes = make(map[string][]Route, len(containers))
+	for _, container := range containers {
+		if _, ok := p.draining[container.ID]; ok {
+			// Stopping, its routes are drained below
+			p.containerRoutes[container.ID] = previous[container.ID]
+			continue
 		}
+		parsed := p.parseContainerLabels(container)
+		if len(parsed.Routes) > 0 {
+			p.containerRoutes[container.ID] = parsed.Routes
+		}
+		config.append(parsed)
 	}
+	for id := range p.draining {
+		if _, ok := p.containerRoutes[id]; !ok {
+			p.containerRoutes[id] = previous[id]
+		}
+	}
+	config.Routes = append(config.Routes, p.drainingRoutes(config.Routes)...)
 
-	return routes
+	return config, nil
+}
+
+func (p *Provider) getSwarmRoutes(ctx context.Context) (GomaConfig, error) {
+	services, err := p.listEnabledServices(ctx)
+	if err != nil {
+		return GomaConfig{}, err
+	}
+	all, err := p.dockerClient.ServiceList(ctx, swarm.ServiceListOptions{})
+	if err != nil {
+		return GomaConfig{}, fmt.Errorf("failed to list services: %w", err)
+	}
+	p.addresses = serviceAddresses(all)
+
+	defaults, err := p.listServiceLabels(ctx, projectDefaultsLabel)
+	if err != nil {
+		return GomaConfig{}, fmt.Errorf("failed to list project defaults services: %w", err)
+	}
+	controllers, err := p.listServiceLabels(ctx, controllerLabel)
+	if err != nil {
+		return GomaConfig{}, fmt.Errorf("failed to list controller services: %w", err)
+	}
+	p.setProjectDefaults(defaults)
+	p.setTraffic(controllers)
+
+	config := GomaConfig{Routes: make([]Route, 0)}
+	for _, service := range services {
+		config.append(p.parseServiceLabels(service))
+	}
+
+	return config, nil
 }
 
-func (p *Provider) parseServiceLabels(service swarm.Service) []Route {
-	labels := service.Spec.Labels
+func (p *Provider) parseContainerLabels(container container.Summary) GomaConfig {
+	containerName := containerName(container)
+	labels, enabled := p.discoveryLabels(containerName, container.Labels)
+	if !enabled {
+		return GomaConfig{}
+	}
+	p.checkLabels(containerName, labels)
 
-	if labels["goma.enable"] != "true" {
-		return nil
+	// Containers sharing another container's network namespace, as in a pod,
+	// are reached through that container
+	host := containerName
+	if owner, ok := p.targetHosts[container.ID]; ok {
+		host = owner
 	}
 
-	serviceName := service.Spec.Name
+	owner := containerOwner(labels, container.Image)
+	labels, ok := p.resolveLabels(containerName, owner, labels)
+	if !ok {
+		return GomaConfig{}
+	}
 
-	// Extract route names
-	routeNames := p.extractRouteNames(labels)
+	return p.parseSource(routeSource{
+		name:  containerName,
+		host:  host,
+		port:  "80",
+		owner: owner,
+	}, labels, time.Unix(container.Created, 0))
+}
 
-	if len(routeNames) == 0 {
-		// single route mode
-		if route := p.parseSingleServiceRoute(service, labels, serviceName); route != nil {
-			return []Route{*route}
-		}
-		return nil
+func (p *Provider) parseServiceLabels(service swarm.Service) GomaConfig {
+	serviceName := service.Spec.Name
+	labels, enabled := p.discoveryLabels(serviceName, service.Spec.Labels)
+	if !enabled {
+		return GomaConfig{}
 	}
+	p.checkLabels(serviceName, labels)
 
-	// Parse named routes
-	routes := make([]Route, 0, len(routeNames))
-	for _, routeName := range routeNames {
-		if route := p.parseNamedServiceRoute(service, labels, routeName, serviceName); route != nil {
-			routes = append(routes, *route)
-		}
+	image := ""
+	if service.Spec.TaskTemplate.ContainerSpec != nil {
+		image = service.Spec.TaskTemplate.ContainerSpec.Image
 	}
+	owner := containerOwner(labels, image)
 
-	return routes
+	labels, ok := p.resolveLabels(serviceName, owner, labels)
+	if !ok {
+		return GomaConfig{}
+	}
+
+	// Swarm mode, use service name as DNS
+	return p.parseSource(routeSource{
+		name:  serviceName,
+		host:  serviceName,
+		port:  servicePort(service),
+		owner: owner,
+	}, labels, service.CreatedAt)
+}
+
+// parseSource builds the routes declared by the labels of a container or
+// service and enforces the host policy on them.
+func (p *Provider) parseSource(src routeSource, labels map[string]string, created time.Time) GomaConfig {
+	routes := p.parseRoutes(src, labels)
+	for i := range routes {
+		routes[i].Created = created
+	}
+	l4Routes := p.parseL4Routes(src, labels)
+	for i := range l4Routes {
+		l4Routes[i].Created = created
+	}
+	return GomaConfig{
+		Routes:   p.enforceHostPolicy(src.name, src.owner, routes),
+		L4Routes: p.enforceL4HostPolicy(src.name, src.owner, l4Routes),
+	}
 }
 
 func (p *Provider) extractRouteNames(labels map[string]string) []string {
@@ -221,147 +452,256 @@ func (p *Provider) extractRouteNames(labels map[string]string) []string {
 	return routes
 }
 
-func (p *Provider) parseNamedContainerRoute(labels map[string]string, routeName, containerName string) *Route {
-	prefix := fmt.Sprintf("goma.routes.%s.", routeName)
+// checkLabels reports goma.* labels that the parser does not know about,
+// which are most often typos.
+func (p *Provider) checkLabels(source string, labels map[string]string) {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
 
-	// Extract route specific labels
-	routeLabels := make(map[string]string)
-	for key, value := range labels {
-		if strings.HasPrefix(key, prefix) {
-			field := strings.TrimPrefix(key, prefix)
-			routeLabels[field] = value
+	named := len(p.extractRouteNames(labels)) > 0
+	for _, key := range keys {
+		if !strings.HasPrefix(key, "goma.") || strings.HasPrefix(key, "goma.traffic.") {
+			// Traffic labels are checked on controllers
+			continue
+		}
+		if field, ok := lookupProviderLabel(key); ok {
+			if err := field.check(labels[key]); err != nil {
+				p.errorf(source, key, "%v", err)
+			}
+			continue
+		}
+		if strings.HasPrefix(key, "goma.tcp.") || strings.HasPrefix(key, "goma.udp.") {
+			if !validL4Label(key) {
+				p.warnf(source, key, "unknown label, it will be ignored")
+			}
+			continue
+		}
+		field := strings.TrimPrefix(key, "goma.")
+		matches := namedRoutePattern.FindStringSubmatch(key)
+		if matches != nil {
+			field = matches[2]
+		}
+		if _, ok := lookupRouteField(field); !ok {
+			p.warnf(source, key, "unknown label, it will be ignored")
+			continue
+		}
+		if named && matches == nil && !namedRouteInherited(field) {
+			p.warnf(source, key, "ignored because the container declares named routes, set goma.routes.{name}.%s instead", field)
 		}
 	}
+}
 
-	// Path is required
-	path, exists := routeLabels["path"]
-	if !exists || path == "" {
-		path = "/"
-	}
+// routeSource describes the container or service routes are parsed from.
+type routeSource struct {
+	// name is the container or service name.
+	name string
+	// host is the backend host of the routes.
+	host string
+	// port is the backend port used when no port label is set.
+	port string
+	// owner identifies the project, stack and image of the source.
+	owner routeOwner
+}
 
-	route := &Route{
-		Name:    getRouteLabel(routeLabels, "name", fmt.Sprintf("%s-%s", containerName, routeName)),
-		Path:    path,
-		Enabled: parseBoolFromMap(routeLabels, "enabled", true),
+// parseRoutes builds the routes declared by the labels of a container or service.
+func (p *Provider) parseRoutes(src routeSource, labels map[string]string) []Route {
+	routeNames := p.extractRouteNames(labels)
+	if len(routeNames) == 0 {
+		if hasL4Labels(labels) && !hasRouteLabels(labels) {
+			// Layer-4 routes only
+			return nil
+		}
+		// single route mode
+		if route, ok := p.parseRoute(src, labels, "goma.", src.name); ok {
+			return []Route{route}
+		}
+		return nil
 	}
 
-	// Build target URL
-	port := getRouteLabel(routeLabels, "port", "80")
-	scheme := getLabel(labels, "goma.scheme", "http")
-	if scheme != "http" && scheme != "https" {
-		scheme = "http"
+	// Parse named routes
+	routes := make([]Route, 0, len(routeNames))
+	for _, routeName := range routeNames {
+		prefix := fmt.Sprintf("goma.routes.%s.", routeName)
+		if route, ok := p.parseRoute(src, labels, prefix, fmt.Sprintf("%s-%s", src.name, routeName)); ok {
+			routes = append(routes, route)
+		}
 	}
-	route.Target = fmt.Sprintf("%s://%s:%s", scheme, containerName, port)
-
-	// Parse all route fields
-	p.parseRouteFields(route, routeLabels)
-
-	return route
+	return routes
 }
 
-func (p *Provider) parseNamedServiceRoute(service swarm.Service, labels map[string]string, routeName, serviceName string) *Route {
-	prefix := fmt.Sprintf("goma.routes.%s.", routeName)
-
-	// Extract route specific labels
-	routeLabels := make(map[string]string)
-	for key, value := range labels {
-		if strings.HasPrefix(key, prefix) {
-			field := strings.TrimPrefix(key, prefix)
-			routeLabels[field] = value
-		}
+// parseRoute builds a route from the fields under prefix. It reports false
+// when the route is rejected by the route policy.
+func (p *Provider) parseRoute(src routeSource, labels map[string]string, prefix, defaultName string) (Route, bool) {
+	fields := routeFields(labels, prefix)
+	if prefix != "goma." {
+		inheritContainerFields(fields, routeFields(labels, "goma."))
 	}
-
-	// Path is required
-	path, exists := routeLabels["path"]
-	if !exists || path == "" {
-		path = "/"
+	sources := make(map[string]string, len(fields))
+	for field := range fields {
+		sources[field] = sourceLabel
 	}
+	applyDefaults(fields, sources, p.projectDefaults[src.owner.projectName()])
+	applyDefaults(fields, sources, p.globalDefaults)
 
-	route := &Route{
-		Name:    getRouteLabel(routeLabels, "name", fmt.Sprintf("%s-%s", serviceName, routeName)),
-		Path:    path,
-		Enabled: parseBoolFromMap(routeLabels, "enabled", true),
+	fc := fieldContext{source: src.name, prefix: prefix}
+	name := p.routeName(getRouteLabel(fields, "name", defaultName), src.owner.projectName())
+	if !p.applyRoutePolicy(fc, name, src.owner, fields, sources) {
+		return Route{}, false
 	}
 
-	// Get service port
-	port := routeLabels["port"]
-	if port == "" && len(service.Spec.EndpointSpec.Ports) > 0 {
-		port = fmt.Sprintf("%d", service.Spec.EndpointSpec.Ports[0].TargetPort)
+	entryPoints, ok := p.parseEntryPoints(fc, name, fields["entrypoints"])
+	if !ok {
+		return Route{}, false
 	}
-	if port == "" {
-		port = "80"
+	middlewares, ok := p.accessMiddlewares(fc, name, fields)
+	if !ok {
+		return Route{}, false
 	}
-
-	// Swarm mode, use service name as DNS
-	scheme := getLabel(labels, "goma.scheme", "http")
-	if scheme != "http" && scheme != "https" {
-		scheme = "http"
+	authMiddlewares, ok := p.authMiddlewares(fc, name, src.owner.projectName(), fields, sources)
+	if !ok {
+		return Route{}, false
 	}
-	route.Target = fmt.Sprintf("%s://%s:%s", scheme, serviceName, port)
-
-	// Parse all route fields
-	p.parseRouteFields(route, routeLabels)
-
-	return route
-}
+	middlewares = append(middlewares, authMiddlewares...)
+	headerMiddlewares, ok := p.headerMiddleware(fc, name, fields)
+	if !ok {
+		return Route{}, false
+	}
+	middlewares = append(middlewares, headerMiddlewares...)
 
-func (p *Provider) parseSingleContainerRoute(labels map[string]string, containerName string) *Route {
-	path := labels["goma.path"]
+	path := fields["path"]
 	if path == "" {
 		path = "/"
 	}
 
-	route := &Route{
-		Name:    getLabel(labels, "goma.name", containerName),
-		Path:    path,
-		Enabled: true,
+	route := Route{
+		Name:        name,
+		Path:        path,
+		Enabled:     p.parseBoolField(fc, fields, "enabled", true),
+		EntryPoints: entryPoints,
+		Source:      src.name,
+		Project:     src.owner.projectName(),
+
+		FieldSources: sources,
 	}
 
-	port := getLabel(labels, "goma.port", "80")
-	scheme := getLabel(labels, "goma.scheme", "http")
-	if scheme != "http" && scheme != "https" {
-		scheme = "http"
+	// Build target URL
+	port := getRouteLabel(fields, "port", src.port)
+	scheme := getRouteLabel(fields, "scheme", "http")
+	if !p.setTarget(fc, &route, scheme, src.host, port) {
+		return Route{}, false
 	}
-	route.Target = fmt.Sprintf("%s://%s:%s", scheme, containerName, port)
 
-	p.parseRouteLabels(route, labels)
+	// Parse all route fields
+	p.parseRouteFields(fc, &route, fields)
+
+	// Generated middlewares run first
+	names := make([]string, 0, len(middlewares)+len(route.Middlewares))
+	for _, middleware := range middlewares {
+		names = append(names, middleware.Name)
+	}
+	route.Middlewares = append(names, route.Middlewares...)
+	route.GeneratedMiddlewares = middlewares
 
-	return route
+	return route, true
 }
 
-func (p *Provider) parseSingleServiceRoute(service swarm.Service, labels map[string]string, serviceName string) *Route {
-	path := labels["goma.path"]
-	if path == "" {
-		path = "/"
+// namedRouteInherited reports whether a container-level field applies to the
+// named routes of the container. These are the fields restricting who can
+// reach a route, so that a named route is never published less protected
+// than the container declares.
+func namedRouteInherited(field string) bool {
+	switch field {
+	case "scheme", "entrypoints", "hosts", "middlewares", "access.allow", "access.deny":
+		return true
 	}
+	return strings.HasPrefix(field, "auth.") || strings.HasPrefix(field, "headers.")
+}
 
-	route := &Route{
-		Name:    getLabel(labels, "goma.name", serviceName),
-		Path:    path,
-		Enabled: true,
+// inheritContainerFields copies the inherited container fields a named route
+// does not set. An authentication method is inherited as a whole, only when
+// the named route sets none of its fields.
+func inheritContainerFields(fields, container map[string]string) {
+	for field, value := range container {
+		if !namedRouteInherited(field) {
+			continue
+		}
+		if _, exists := fields[field]; exists {
+			continue
+		}
+		if method, ok := authMethod(field); ok && hasFieldPrefix(fields, method) {
+			continue
+		}
+		fields[field] = value
 	}
+}
 
-	// Get service port
-	port := labels["goma.port"]
-	if port == "" && len(service.Spec.EndpointSpec.Ports) > 0 {
-		port = fmt.Sprintf("%d", service.Spec.EndpointSpec.Ports[0].TargetPort)
+// authMethod returns the field prefix of the authentication method of a field.
+func authMethod(field string) (string, bool) {
+	for _, method := range []string{"auth.basic.", "auth.forward.", "auth.jwt."} {
+		if strings.HasPrefix(field, method) {
+			return method, true
+		}
 	}
-	if port == "" {
-		port = "80"
+	return "", false
+}
+
+func hasFieldPrefix(fields map[string]string, prefix string) bool {
+	for field := range fields {
+		if strings.HasPrefix(field, prefix) {
+			return true
+		}
 	}
-	scheme := getLabel(labels, "goma.scheme", "http")
-	if scheme != "http" && scheme != "https" {
-		scheme = "http"
+	return false
+}
+
+// servicePort returns the first published target port of a service, or 80.
+func servicePort(service swarm.Service) string {
+	if service.Spec.EndpointSpec != nil && len(service.Spec.EndpointSpec.Ports) > 0 {
+		return fmt.Sprintf("%d", service.Spec.EndpointSpec.Ports[0].TargetPort)
 	}
-	route.Target = fmt.Sprintf("%s://%s:%s", scheme, serviceName, port)
+	return "80"
+}
+
+// backendScheme maps a goma.scheme value to the scheme of the target URL
+// and the route flags the backend protocol needs.
+type backendScheme struct {
+	target    string
+	h2c       bool
+	websocket bool
+}
 
-	// Parse labels
-	p.parseRouteLabels(route, labels)
+var backendSchemes = map[string]backendScheme{
+	"http":  {target: "http"},
+	"https": {target: "https"},
+	"h2c":   {target: "http", h2c: true},
+	"grpc":  {target: "http", h2c: true},
+	"grpcs": {target: "https"},
+	"ws":    {target: "http", websocket: true},
+	"wss":   {target: "https", websocket: true},
+}
 
-	return route
+// setTarget sets the backend URL of a route and the flags of its protocol.
+// Routes with an unsupported scheme or an invalid port are rejected.
+func (p *Provider) setTarget(fc fieldContext, route *Route, scheme, host, port string) bool {
+	backend, ok := backendSchemes[scheme]
+	if !ok {
+		p.errorf(fc.source, fc.key("scheme"), "route %q rejected: unsupported scheme %q", route.Name, scheme)
+		return false
+	}
+	if val, err := strconv.Atoi(port); err != nil || val < 1 || val > 65535 {
+		p.errorf(fc.source, fc.key("port"), "route %q rejected: invalid port %q", route.Name, port)
+		return false
+	}
+	route.Target = fmt.Sprintf("%s://%s:%s", backend.target, host, port)
+	route.H2C = backend.h2c
+	route.EnableWebSocket = backend.websocket
+	return true
 }
 
-func (p *Provider) parseRouteFields(route *Route, labels map[string]string) {
+func (p *Provider) parseRouteFields(fc fieldContext, route *Route, labels map[string]string) {
 	// Basic fields
 	if rewrite := labels["rewrite"]; rewrite != "" {
 		route.Rewrite = rewrite
@@ -370,6 +710,8 @@ func (p *Provider) parseRouteFields(route *Route, labels map[string]string) {
 	if priority := labels["priority"]; priority != "" {
 		if val, err := strconv.Atoi(priority); err == nil {
 			route.Priority = val
+		} else {
+			p.errorf(fc.source, fc.key("priority"), "invalid priority %q, expected an integer", priority)
 		}
 	}
 
@@ -393,79 +735,96 @@ func (p *Provider) parseRouteFields(route *Route, labels map[string]string) {
 
 		if statuses := labels["health_check.healthy_statuses"]; statuses != "" {
 			route.HealthCheck.HealthyStatuses = parseIntList(statuses)
+			if len(route.HealthCheck.HealthyStatuses) != len(parseList(statuses)) {
+				p.errorf(fc.source, fc.key("health_check.healthy_statuses"), "invalid status list %q", statuses)
+			}
+		}
+	} else {
+		for _, field := range []string{"health_check.interval", "health_check.timeout", "health_check.healthy_statuses"} {
+			if route.FieldSources[field] == sourceLabel {
+				p.warnf(fc.source, fc.key(field), "ignored because %s is not set", fc.key("health_check.path"))
+			}
 		}
 	}
 
 	// Security
 	route.Security = Security{
-		ForwardHostHeaders:      parseBoolFromMap(labels, "security.forward_host_headers", true),
-		EnableExploitProtection: parseBoolFromMap(labels, "security.enable_exploit_protection", false),
+		ForwardHostHeaders:      p.parseBoolField(fc, labels, "security.forward_host_headers", true),
+		EnableExploitProtection: p.parseBoolField(fc, labels, "security.enable_exploit_protection", false),
 		TLS: SecurityTLS{
-			InsecureSkipVerify: parseBoolFromMap(labels, "security.tls.insecure_skip_verify", false),
+			InsecureSkipVerify: p.parseBoolField(fc, labels, "security.tls.insecure_skip_verify", false),
 		},
 	}
 
 	// Metrics
-	route.DisableMetrics = parseBoolFromMap(labels, "disable_metrics", false)
+	route.DisableMetrics = p.parseBoolField(fc, labels, "disable_metrics", false)
 
 	// Middlewares
 	if middlewares := labels["middlewares"]; middlewares != "" {
 		route.Middlewares = parseList(middlewares)
 	}
-}
-
-func (p *Provider) parseRouteLabels(route *Route, labels map[string]string) {
-	// Basic fields
-	if rewrite := labels["goma.rewrite"]; rewrite != "" {
-		route.Rewrite = rewrite
-	}
 
-	if priority := labels["goma.priority"]; priority != "" {
-		if val, err := strconv.Atoi(priority); err == nil {
-			route.Priority = val
+	// Load balancing
+	if algorithm := labels["lb.algorithm"]; algorithm != "" {
+		field, _ := lookupRouteField("lb.algorithm")
+		if err := field.check(algorithm); err == nil {
+			route.LoadBalancing.Algorithm = algorithm
+		} else {
+			p.errorf(fc.source, fc.key("lb.algorithm"), "invalid algorithm %q, expected one of %s", algorithm, strings.Join(field.values, ", "))
 		}
 	}
-
-	// Hosts
-	if hosts := labels["goma.hosts"]; hosts != "" {
-		route.Hosts = parseList(hosts)
-	}
-
-	// Methods
-	if methods := labels["goma.methods"]; methods != "" {
-		route.Methods = parseList(methods)
+	if cookie := labels["sticky.cookie_name"]; cookie != "" {
+		if validToken(cookie) {
+			route.LoadBalancing.StickySession.CookieName = cookie
+		} else {
+			p.errorf(fc.source, fc.key("sticky.cookie_name"), "invalid cookie name %q", cookie)
+		}
+		if ttl := labels["sticky.ttl"]; ttl != "" {
+			if d, err := time.ParseDuration(ttl); err == nil && d > 0 {
+				route.LoadBalancing.StickySession.TTL = ttl
+			} else {
+				p.errorf(fc.source, fc.key("sticky.ttl"), "invalid duration %q", ttl)
+			}
+		}
+	} else if route.FieldSources["sticky.ttl"] == sourceLabel {
+		p.warnf(fc.source, fc.key("sticky.ttl"), "ignored because %s is not set", fc.key("sticky.cookie_name"))
 	}
 
-	// Health Check
-	if hcPath := labels["goma.health_check.path"]; hcPath != "" {
-		route.HealthCheck = RouteHealthCheck{
-			Path:     hcPath,
-			Interval: getLabel(labels, "goma.health_check.interval", "30s"),
-			Timeout:  getLabel(labels, "goma.health_check.timeout", "5s"),
+	// Traffic shifting
+	if color := labels["color"]; color != "" {
+		if color == "blue" || color == "green" {
+			route.Color = color
+		} else {
+			p.errorf(fc.source, fc.key("color"), "invalid color %q, expected blue or green", color)
 		}
-
-		// Parse healthy statuses
-		if statuses := labels["goma.health_check.healthy_statuses"]; statuses != "" {
-			route.HealthCheck.HealthyStatuses = parseIntList(statuses)
+	}
+	if canaryOf := labels["canary.of"]; canaryOf != "" {
+		route.CanaryOf = canaryOf
+		route.CanaryWeight = -1
+		if weight, exists := labels["canary.weight"]; exists {
+			if val, err := strconv.Atoi(weight); err == nil && val >= 0 && val <= 100 {
+				route.CanaryWeight = val
+			} else {
+				p.errorf(fc.source, fc.key("canary.weight"), "invalid weight %q, expected an integer from 0 to 100", weight)
+			}
 		}
+	} else if route.FieldSources["canary.weight"] == sourceLabel {
+		p.warnf(fc.source, fc.key("canary.weight"), "ignored because %s is not set", fc.key("canary.of"))
 	}
+}
 
-	// Security
-	route.Security = Security{
-		ForwardHostHeaders:      parseBool(labels, "goma.security.forward_host_headers", true),
-		EnableExploitProtection: parseBool(labels, "goma.security.enable_exploit_protection", false),
-		TLS: SecurityTLS{
-			InsecureSkipVerify: parseBool(labels, "goma.security.tls.insecure_skip_verify", false),
-		},
+// parseBoolField parses a boolean route field, reporting values that are not booleans.
+func (p *Provider) parseBoolField(fc fieldContext, labels map[string]string, field string, defaultValue bool) bool {
+	value, exists := labels[field]
+	if !exists {
+		return defaultValue
 	}
-
-	// Metrics
-	route.DisableMetrics = parseBool(labels, "goma.disable_metrics", false)
-
-	// Middlewares
-	if middlewares := labels["goma.middlewares"]; middlewares != "" {
-		route.Middlewares = parseList(middlewares)
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		p.errorf(fc.source, fc.key(field), "invalid boolean %q", value)
+		return defaultValue
 	}
+	return parsed
 }
 
 func (p *Provider) writeConfiguration(config GomaConfig) error {
@@ -473,21 +832,49 @@ func (p *Provider) writeConfiguration(config GomaConfig) error {
 		return err
 	}
 
-	filename := filepath.Join(p.config.OutputDir, outputFile)
-
-	data, err := yaml.Marshal(config)
+	data, err := MarshalConfiguration(config, FormatYAML, p.config.TargetVersion)
 	if err != nil {
-		return fmt.Errorf("failed to marshal configuration: %w", err)
+		return err
 	}
 
-	header := []byte(`# Generated by Goma Gateway Docker provider
-# DO NOT EDIT MANUALLY
+	if err := os.WriteFile(p.OutputFile(), data, 0644); err != nil {
+		return err
+	}
 
-`)
+	if history := p.History(); history != nil {
+		if err := history.save(data); err != nil {
+			logger.Error("Failed to save configuration snapshot", "error", err)
+		}
+	}
+	return nil
+}
 
-	data = append(header, data...)
+// MarshalConfiguration encodes the configuration as it is written to the
+// output file, for a target gateway version.
+func MarshalConfiguration(config GomaConfig, format, version string) ([]byte, error) {
+	if version == "" {
+		version = TargetVersion2
+	}
+	target := lookupTargetVersion(version)
+	document := target.document(config)
+	if format == FormatJSON {
+		if target.header {
+			// JSON has no comments to record the version in
+			return nil, fmt.Errorf("JSON output is not supported for gateway version %s, use YAML", version)
+		}
+		data, err := json.MarshalIndent(document, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal configuration: %w", err)
+		}
+		return append(data, '\n'), nil
+	}
+
+	data, err := yaml.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal configuration: %w", err)
+	}
 
-	return os.WriteFile(filename, data, 0644)
+	return append([]byte(versionHeader(version)), data...), nil
 }
 
 func (p *Provider) calculateHash(config GomaConfig) string {
@@ -0,0 +1,266 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/jkaninda/goma-docker-provider/internal/config"
+)
+
+// parseTestContainer parses the labels of a running container named name.
+func parseTestContainer(p *Provider, name string, labels map[string]string) GomaConfig {
+	return p.parseContainerLabels(container.Summary{ID: name + "-id", Names: []string{"/" + name}, Labels: labels})
+}
+
+func TestSetTarget(t *testing.T) {
+	tests := []struct {
+		scheme, port string
+		want         Route
+		ok           bool
+	}{
+		{"http", "8080", Route{Target: "http://web:8080"}, true},
+		{"https", "443", Route{Target: "https://web:443"}, true},
+		{"grpc", "50051", Route{Target: "http://web:50051", H2C: true}, true},
+		{"grpcs", "50051", Route{Target: "https://web:50051"}, true},
+		{"wss", "8443", Route{Target: "https://web:8443", EnableWebSocket: true}, true},
+		{"ftp", "21", Route{}, false},
+		{"http", "http", Route{}, false},
+		{"http", "0", Route{}, false},
+		{"http", "65536", Route{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.scheme+" "+tt.port, func(t *testing.T) {
+			p := NewProvider(config.Default())
+			var route Route
+			ok := p.setTarget(fieldContext{source: "web"}, &route, tt.scheme, "web", tt.port)
+			if ok != tt.ok {
+				t.Fatalf("setTarget() = %v, want %v (diagnostics: %v)", ok, tt.ok, p.diagnostics)
+			}
+			if ok != !hasErrors(p.diagnostics) {
+				t.Errorf("diagnostics = %v", p.diagnostics)
+			}
+			if ok && (route.Target != tt.want.Target || route.H2C != tt.want.H2C || route.EnableWebSocket != tt.want.EnableWebSocket) {
+				t.Errorf("route = %+v, want %+v", route, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvalidPortRejectsRoute(t *testing.T) {
+	p := NewProvider(config.Default())
+	parsed := parseTestContainer(p, "web", map[string]string{
+		"goma.enable": "true",
+		"goma.port":   "99999",
+	})
+	if len(parsed.Routes) != 0 {
+		t.Errorf("routes = %v, want the route rejected", parsed.Routes)
+	}
+	if !hasErrors(p.diagnostics) {
+		t.Error("expected an invalid port error")
+	}
+}
+
+func TestParseSingleRoute(t *testing.T) {
+	p := NewProvider(config.Default())
+	parsed := parseTestContainer(p, "web", map[string]string{
+		"goma.enable":   "true",
+		"goma.port":     "8080",
+		"goma.path":     "/api",
+		"goma.hosts":    "example.com, www.example.com",
+		"goma.methods":  "GET,POST",
+		"goma.priority": "10",
+		"goma.rewrite":  "/",
+	})
+	if len(parsed.Routes) != 1 {
+		t.Fatalf("routes = %v, want one route", parsed.Routes)
+	}
+	route := parsed.Routes[0]
+	if route.Name != "web" || route.Path != "/api" || route.Target != "http://web:8080" ||
+		route.Priority != 10 || route.Rewrite != "/" || !route.Enabled {
+		t.Errorf("route = %+v", route)
+	}
+	if !equalStrings(route.Hosts, []string{"example.com", "www.example.com"}) {
+		t.Errorf("hosts = %v", route.Hosts)
+	}
+	if !equalStrings(route.Methods, []string{"GET", "POST"}) {
+		t.Errorf("methods = %v", route.Methods)
+	}
+	if route.FieldSources["port"] != sourceLabel {
+		t.Errorf("port source = %q, want %q", route.FieldSources["port"], sourceLabel)
+	}
+	if len(p.diagnostics) != 0 {
+		t.Errorf("diagnostics = %v", p.diagnostics)
+	}
+}
+
+func TestParseNamedRoutes(t *testing.T) {
+	p := NewProvider(config.Default())
+	parsed := parseTestContainer(p, "app", map[string]string{
+		"goma.enable":              "true",
+		"goma.scheme":              "https",
+		"goma.routes.api.port":     "8443",
+		"goma.routes.api.path":     "/api",
+		"goma.routes.admin.port":   "9443",
+		"goma.routes.admin.path":   "/admin",
+		"goma.routes.admin.scheme": "http",
+		"goma.routes.admin.name":   "backoffice",
+	})
+	if len(parsed.Routes) != 2 {
+		t.Fatalf("routes = %v, want two routes", parsed.Routes)
+	}
+	// Routes are sorted by route label name
+	admin, api := parsed.Routes[0], parsed.Routes[1]
+	if admin.Name != "backoffice" || admin.Target != "http://app:9443" || admin.Path != "/admin" {
+		t.Errorf("admin route = %+v", admin)
+	}
+	// The container scheme is inherited
+	if api.Name != "app-api" || api.Target != "https://app:8443" || api.Path != "/api" {
+		t.Errorf("api route = %+v", api)
+	}
+	if len(p.diagnostics) != 0 {
+		t.Errorf("diagnostics = %v", p.diagnostics)
+	}
+}
+
+func TestParseInvalidFields(t *testing.T) {
+	tests := []struct {
+		label, value string
+	}{
+		{"goma.priority", "high"},
+		{"goma.enabled", "maybe"},
+		{"goma.security.tls.insecure_skip_verify", "yes please"},
+		{"goma.health_check.healthy_statuses", "200,ok"},
+		{"goma.lb.algorithm", "random"},
+		{"goma.color", "red"},
+		{"goma.canary.weight", "150"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			p := NewProvider(config.Default())
+			labels := map[string]string{
+				"goma.enable":            "true",
+				"goma.health_check.path": "/health",
+				"goma.canary.of":         "web",
+				tt.label:                 tt.value,
+			}
+			parseTestContainer(p, "web", labels)
+			if !hasErrors(p.diagnostics) {
+				t.Fatalf("no error for %s=%q", tt.label, tt.value)
+			}
+			if p.diagnostics[0].Label != tt.label {
+				t.Errorf("diagnostic label = %q, want %q", p.diagnostics[0].Label, tt.label)
+			}
+		})
+	}
+}
+
+func TestCheckLabels(t *testing.T) {
+	p := NewProvider(config.Default())
+	p.checkLabels("web", map[string]string{
+		"goma.enable":                "true",
+		"goma.port":                  "80",
+		"goma.routes.api.hosts":      "example.com",
+		"goma.headers.request.set.X": "1",
+		"goma.tcp.port":              "5432",
+		"goma.hots":                  "example.com",
+		"goma.drain_period":          "soon",
+		"other.label":                "ignored",
+	})
+	want := []struct {
+		label    string
+		severity Severity
+	}{
+		{drainPeriodLabel, SeverityError},
+		{"goma.hots", SeverityWarning},
+		// Not inherited by the named route
+		{"goma.port", SeverityWarning},
+	}
+	if len(p.diagnostics) != len(want) {
+		t.Fatalf("diagnostics = %v, want %d", p.diagnostics, len(want))
+	}
+	for i, w := range want {
+		if d := p.diagnostics[i]; d.Label != w.label || d.Severity != w.severity {
+			t.Errorf("diagnostic %d = %+v, want %s %s", i, d, w.severity, w.label)
+		}
+	}
+}
+
+func TestNamedRoutesInheritProtection(t *testing.T) {
+	p := NewProvider(config.Default())
+	parsed := parseTestContainer(p, "app", map[string]string{
+		"goma.enable":                          "true",
+		"goma.hosts":                           "app.example.com",
+		"goma.auth.basic.users":                "admin:" + testBcryptHash,
+		"goma.headers.response.set.X-Frame":    "DENY",
+		"goma.routes.api.path":                 "/api",
+		"goma.routes.public.path":              "/public",
+		"goma.routes.public.hosts":             "www.example.com",
+		"goma.routes.public.auth.jwt.jwks_url": "https://idp.example.com/jwks.json",
+	})
+	if hasErrors(p.diagnostics) || hasWarnings(p.diagnostics) {
+		t.Fatalf("diagnostics = %v", p.diagnostics)
+	}
+	if len(parsed.Routes) != 2 {
+		t.Fatalf("routes = %v, want two routes", parsed.Routes)
+	}
+	api, public := parsed.Routes[0], parsed.Routes[1]
+
+	if !equalStrings(api.Hosts, []string{"app.example.com"}) {
+		t.Errorf("api hosts = %v, want the container hosts", api.Hosts)
+	}
+	want := []string{"docker-app-api-basic-auth", "docker-app-api-headers"}
+	if !equalStrings(api.Middlewares, want) {
+		t.Errorf("api middlewares = %v, want %v", api.Middlewares, want)
+	}
+
+	// Fields set on the named route take precedence
+	if !equalStrings(public.Hosts, []string{"www.example.com"}) {
+		t.Errorf("public hosts = %v", public.Hosts)
+	}
+	want = []string{"docker-app-public-basic-auth", "docker-app-public-jwt-auth", "docker-app-public-headers"}
+	if !equalStrings(public.Middlewares, want) {
+		t.Errorf("public middlewares = %v, want %v", public.Middlewares, want)
+	}
+}
+
+func TestNamedRouteAuthMethodOverride(t *testing.T) {
+	p := NewProvider(config.Default())
+	parsed := parseTestContainer(p, "app", map[string]string{
+		"goma.enable":                      "true",
+		"goma.auth.basic.users":            "admin:" + testBcryptHash,
+		"goma.auth.basic.realm":            "App",
+		"goma.routes.api.auth.basic.users": "api:" + testBcryptHash,
+	})
+	if len(parsed.Routes) != 1 || len(parsed.Routes[0].GeneratedMiddlewares) != 1 {
+		t.Fatalf("routes = %+v, want one route with basic auth", parsed.Routes)
+	}
+	rule := parsed.Routes[0].GeneratedMiddlewares[0].Rule.(BasicAuthRule)
+	// The container realm belongs to the container users, it is not mixed in
+	if len(rule.Users) != 1 || rule.Users[0].Username != "api" || rule.Realm != "" {
+		t.Errorf("rule = %+v, want the route users only", rule)
+	}
+}
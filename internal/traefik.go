@@ -0,0 +1,410 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// traefikEnableLabel enables the translation of the Traefik labels of a
+// container or service, in Traefik compatibility mode.
+const traefikEnableLabel = "traefik.enable"
+
+var (
+	traefikRouterPattern  = regexp.MustCompile(`^traefik\.http\.routers\.([^.]+)\.(.+)$`)
+	traefikServicePattern = regexp.MustCompile(`^traefik\.http\.services\.([^.]+)\.(.+)$`)
+)
+
+// traefikServiceFields maps Traefik service options to route fields.
+var traefikServiceFields = map[string]string{
+	"loadbalancer.server.port":          "port",
+	"loadbalancer.server.scheme":        "scheme",
+	"loadbalancer.healthcheck.path":     "health_check.path",
+	"loadbalancer.healthcheck.interval": "health_check.interval",
+	"loadbalancer.healthcheck.timeout":  "health_check.timeout",
+}
+
+// enableLabels returns the labels enabling route discovery.
+func (p *Provider) enableLabels() []string {
+	if p.config.TraefikCompat {
+		return []string{"goma.enable", traefikEnableLabel}
+	}
+	return []string{"goma.enable"}
+}
+
+// discoveryLabels returns the labels the routes of a container or service are
+// parsed from, and false when discovery is not enabled. Traefik labels are
+// translated when goma.enable is not set, in Traefik compatibility mode.
+func (p *Provider) discoveryLabels(source string, labels map[string]string) (map[string]string, bool) {
+	if labels["goma.enable"] == "true" {
+		return labels, true
+	}
+	if !p.config.TraefikCompat || labels[traefikEnableLabel] != "true" {
+		return nil, false
+	}
+	translated := p.translateTraefikLabels(source, labels)
+	return translated, translated != nil
+}
+
+// translateTraefikLabels returns the labels with the goma.routes.* labels
+// equivalent to the Traefik HTTP routers added, one named route per router.
+// Goma labels already set take precedence. It returns nil when no router
+// can be translated.
+func (p *Provider) translateTraefikLabels(source string, labels map[string]string) map[string]string {
+	routers := make(map[string]map[string]string)
+	services := make(map[string]map[string]string)
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if !strings.HasPrefix(key, "traefik.") || key == traefikEnableLabel {
+			continue
+		}
+		if matches := traefikRouterPattern.FindStringSubmatch(key); matches != nil {
+			addOption(routers, matches[1], matches[2], labels[key])
+			continue
+		}
+		if matches := traefikServicePattern.FindStringSubmatch(key); matches != nil {
+			field, ok := traefikServiceFields[matches[2]]
+			switch {
+			case ok:
+				addOption(services, matches[1], field, labels[key])
+			case matches[2] == "loadbalancer.passhostheader":
+				addOption(services, matches[1], "security.forward_host_headers", labels[key])
+			default:
+				p.warnf(source, key, "Traefik service option not translated, it will be ignored")
+			}
+			continue
+		}
+		p.warnf(source, key, "Traefik label not translated, only HTTP routers and services are supported")
+	}
+	if len(routers) == 0 {
+		p.warnf(source, traefikEnableLabel, "no Traefik router declared, the default Traefik rule is not translated")
+		return nil
+	}
+
+	translated := maps.Clone(labels)
+	names := make([]string, 0, len(routers))
+	for name := range routers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	count := 0
+	for _, name := range names {
+		fields, ok := p.translateRouter(source, name, routers[name], services)
+		if !ok {
+			continue
+		}
+		for field, value := range fields {
+			key := fmt.Sprintf("goma.routes.%s.%s", name, field)
+			if _, exists := translated[key]; !exists {
+				translated[key] = value
+			}
+		}
+		count++
+	}
+	if count == 0 {
+		return nil
+	}
+	translated["goma.enable"] = "true"
+	return translated
+}
+
+func addOption(options map[string]map[string]string, name, option, value string) {
+	if options[name] == nil {
+		options[name] = make(map[string]string)
+	}
+	options[name][option] = value
+}
+
+// translateRouter returns the route fields of a Traefik router and its
+// service. It reports false when the router cannot be translated.
+func (p *Provider) translateRouter(source, name string, router map[string]string, services map[string]map[string]string) (map[string]string, bool) {
+	prefix := fmt.Sprintf("traefik.http.routers.%s.", name)
+	fields := make(map[string]string)
+
+	value, ok := router["rule"]
+	if !ok {
+		p.errorf(source, prefix+"rule", "router %q skipped: no rule", name)
+		return nil, false
+	}
+	rule, err := parseTraefikRule(value)
+	if err != nil {
+		p.errorf(source, prefix+"rule", "router %q skipped: cannot translate rule %q: %v", name, value, err)
+		return nil, false
+	}
+	if rule.exactPath {
+		// Routes match path prefixes, the route would expose more paths than the router
+		p.errorf(source, prefix+"rule", "router %q skipped: exact Path is not supported, use PathPrefix", name)
+		return nil, false
+	}
+	if len(rule.hosts) > 0 {
+		fields["hosts"] = strings.Join(rule.hosts, ",")
+	}
+	if rule.path != "" {
+		fields["path"] = rule.path
+	}
+	if len(rule.methods) > 0 {
+		fields["methods"] = strings.Join(rule.methods, ",")
+	}
+
+	for _, option := range sortedKeys(router) {
+		value := router[option]
+		switch option {
+		case "rule":
+		case "service":
+		case "entrypoints":
+			fields["entrypoints"] = value
+		case "priority":
+			fields["priority"] = value
+		case "middlewares":
+			middlewares := parseList(value)
+			for i, middleware := range middlewares {
+				// Drop the Traefik provider namespace, as in auth@docker
+				middlewares[i], _, _ = strings.Cut(middleware, "@")
+			}
+			fields["middlewares"] = strings.Join(middlewares, ",")
+			p.infof(source, prefix+option, "router %q references middlewares %s, they must be declared in the gateway configuration", name, strings.Join(middlewares, ", "))
+		default:
+			if option == "tls" || strings.HasPrefix(option, "tls.") {
+				p.infof(source, prefix+option, "ignored, TLS is configured on the gateway")
+				continue
+			}
+			p.warnf(source, prefix+option, "Traefik router option not translated, it will be ignored")
+		}
+	}
+
+	service, ok := router["service"]
+	if !ok && len(services) > 1 {
+		p.errorf(source, prefix+"service", "router %q skipped: no service set and several services are declared", name)
+		return nil, false
+	}
+	if !ok {
+		for only := range services {
+			service = only
+		}
+	}
+	if service != "" {
+		options, declared := services[service]
+		if !declared {
+			p.errorf(source, prefix+"service", "router %q skipped: service %q is not declared on this container", name, service)
+			return nil, false
+		}
+		for field, value := range options {
+			fields[field] = value
+		}
+	}
+	return fields, true
+}
+
+// traefikRule is the part of a Traefik router rule a route can express.
+type traefikRule struct {
+	hosts     []string
+	path      string
+	exactPath bool
+	methods   []string
+}
+
+// ruleNode is a node of a parsed Traefik rule: a matcher, or an && or || of nodes.
+type ruleNode struct {
+	op       string
+	matcher  string
+	args     []string
+	children []ruleNode
+}
+
+// parseTraefikRule translates a rule made of Host, PathPrefix, Path and
+// Method matchers joined by &&. || is supported between matchers of the same
+// kind, Host or Method, as their values become lists.
+func parseTraefikRule(rule string) (traefikRule, error) {
+	parser := ruleParser{input: rule}
+	node, err := parser.parseOr()
+	if err != nil {
+		return traefikRule{}, err
+	}
+	parser.skipSpaces()
+	if parser.pos < len(parser.input) {
+		return traefikRule{}, fmt.Errorf("unexpected %q", parser.input[parser.pos:])
+	}
+	var result traefikRule
+	if err := result.apply(node); err != nil {
+		return traefikRule{}, err
+	}
+	return result, nil
+}
+
+func (r *traefikRule) apply(node ruleNode) error {
+	switch node.op {
+	case "&&":
+		for _, child := range node.children {
+			if err := r.apply(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "||":
+		merged := ruleNode{matcher: node.children[0].matcher}
+		for _, child := range node.children {
+			if child.op != "" || child.matcher != merged.matcher || (merged.matcher != "Host" && merged.matcher != "Method") {
+				return errors.New("|| is only supported between Host matchers or between Method matchers")
+			}
+			merged.args = append(merged.args, child.args...)
+		}
+		return r.apply(merged)
+	}
+
+	switch node.matcher {
+	case "Host":
+		if r.hosts != nil {
+			return errors.New("Host is matched more than once")
+		}
+		r.hosts = node.args
+	case "Method":
+		if r.methods != nil {
+			return errors.New("Method is matched more than once")
+		}
+		for _, method := range node.args {
+			r.methods = append(r.methods, strings.ToUpper(method))
+		}
+	case "PathPrefix", "Path":
+		if r.path != "" {
+			return errors.New("the path is matched more than once")
+		}
+		if len(node.args) != 1 {
+			return fmt.Errorf("%s takes a single path", node.matcher)
+		}
+		r.path = node.args[0]
+		r.exactPath = node.matcher == "Path"
+	default:
+		return fmt.Errorf("matcher %s is not supported", node.matcher)
+	}
+	return nil
+}
+
+// ruleParser parses Traefik rules by recursive descent.
+type ruleParser struct {
+	input string
+	pos   int
+}
+
+func (p *ruleParser) skipSpaces() {
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// consume skips token when it comes next.
+func (p *ruleParser) consume(token string) bool {
+	p.skipSpaces()
+	if strings.HasPrefix(p.input[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+func (p *ruleParser) parseOr() (ruleNode, error) {
+	return p.parseBinary("||", p.parseAnd)
+}
+
+func (p *ruleParser) parseAnd() (ruleNode, error) {
+	return p.parseBinary("&&", p.parseOperand)
+}
+
+func (p *ruleParser) parseBinary(op string, operand func() (ruleNode, error)) (ruleNode, error) {
+	node, err := operand()
+	if err != nil {
+		return ruleNode{}, err
+	}
+	children := []ruleNode{node}
+	for p.consume(op) {
+		if node, err = operand(); err != nil {
+			return ruleNode{}, err
+		}
+		children = append(children, node)
+	}
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return ruleNode{op: op, children: children}, nil
+}
+
+func (p *ruleParser) parseOperand() (ruleNode, error) {
+	if p.consume("!") {
+		return ruleNode{}, errors.New("negation is not supported")
+	}
+	if p.consume("(") {
+		node, err := p.parseOr()
+		if err != nil {
+			return ruleNode{}, err
+		}
+		if !p.consume(")") {
+			return ruleNode{}, errors.New("missing )")
+		}
+		return node, nil
+	}
+	return p.parseMatcher()
+}
+
+// parseMatcher parses Name(`arg`, ...), with backquoted or double-quoted arguments.
+func (p *ruleParser) parseMatcher() (ruleNode, error) {
+	p.skipSpaces()
+	start := p.pos
+	for p.pos < len(p.input) && (p.input[p.pos] >= 'A' && p.input[p.pos] <= 'Z' || p.input[p.pos] >= 'a' && p.input[p.pos] <= 'z') {
+		p.pos++
+	}
+	node := ruleNode{matcher: p.input[start:p.pos]}
+	if node.matcher == "" || !p.consume("(") {
+		return ruleNode{}, fmt.Errorf("expected a matcher at %q", p.input[start:])
+	}
+	for {
+		p.skipSpaces()
+		if p.pos >= len(p.input) || (p.input[p.pos] != '`' && p.input[p.pos] != '"') {
+			return ruleNode{}, fmt.Errorf("expected a quoted argument to %s", node.matcher)
+		}
+		quote := p.input[p.pos]
+		end := strings.IndexByte(p.input[p.pos+1:], quote)
+		if end < 0 {
+			return ruleNode{}, fmt.Errorf("unterminated argument to %s", node.matcher)
+		}
+		node.args = append(node.args, p.input[p.pos+1:p.pos+1+end])
+		p.pos += end + 2
+		if p.consume(")") {
+			return node, nil
+		}
+		if !p.consume(",") {
+			return ruleNode{}, fmt.Errorf("expected , or ) in %s", node.matcher)
+		}
+	}
+}
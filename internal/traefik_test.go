@@ -0,0 +1,142 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jkaninda/goma-docker-provider/internal/config"
+)
+
+func TestParseTraefikRule(t *testing.T) {
+	tests := []struct {
+		rule string
+		want traefikRule
+	}{
+		{"Host(`example.com`)", traefikRule{hosts: []string{"example.com"}}},
+		{"Host(`a.example.com`, `b.example.com`)", traefikRule{hosts: []string{"a.example.com", "b.example.com"}}},
+		{"Host(`a.example.com`) || Host(`b.example.com`)", traefikRule{hosts: []string{"a.example.com", "b.example.com"}}},
+		{`Host("example.com") && PathPrefix("/api")`, traefikRule{hosts: []string{"example.com"}, path: "/api"}},
+		{"PathPrefix(`/api`) && (Method(`get`) || Method(`POST`))", traefikRule{path: "/api", methods: []string{"GET", "POST"}}},
+		{"  Host(`example.com`)&&Path(`/health`)  ", traefikRule{hosts: []string{"example.com"}, path: "/health", exactPath: true}},
+		{"(Host(`example.com`))", traefikRule{hosts: []string{"example.com"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			got, err := parseTraefikRule(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTraefikRule() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTraefikRuleErrors(t *testing.T) {
+	tests := []struct {
+		rule string
+		err  string
+	}{
+		{"", "expected a matcher"},
+		{"HostRegexp(`{sub:[a-z]+}.example.com`)", "matcher HostRegexp is not supported"},
+		{"Headers(`X-Env`, `prod`)", "matcher Headers is not supported"},
+		{"!Host(`example.com`)", "negation is not supported"},
+		{"Host(`a.example.com`) || PathPrefix(`/api`)", "|| is only supported"},
+		{"Host(`a.example.com`) && Host(`b.example.com`)", "Host is matched more than once"},
+		{"PathPrefix(`/a`) && PathPrefix(`/b`)", "the path is matched more than once"},
+		{"PathPrefix(`/a`, `/b`)", "PathPrefix takes a single path"},
+		{"(Host(`example.com`)", "missing )"},
+		{"Host(example.com)", "expected a quoted argument"},
+		{"Host(`example.com)", "unterminated argument"},
+		{"Host(`example.com` `b`)", "expected , or )"},
+		{"Host(`example.com`) extra", "unexpected"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			_, err := parseTraefikRule(tt.rule)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("parseTraefikRule() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestTranslateTraefikLabels(t *testing.T) {
+	cfg := config.Default()
+	cfg.TraefikCompat = true
+	p := NewProvider(cfg)
+	labels, ok := p.discoveryLabels("whoami", map[string]string{
+		"traefik.enable":                                        "true",
+		"traefik.http.routers.whoami.rule":                      "Host(`whoami.example.com`) && PathPrefix(`/api`)",
+		"traefik.http.routers.whoami.entrypoints":               "websecure",
+		"traefik.http.routers.whoami.middlewares":               "auth@docker",
+		"traefik.http.routers.health.rule":                      "Path(`/health`)",
+		"traefik.http.services.whoami.loadbalancer.server.port": "8080",
+	})
+	if !ok {
+		t.Fatalf("discovery not enabled: %v", p.diagnostics)
+	}
+	want := map[string]string{
+		"goma.enable":                    "true",
+		"goma.routes.whoami.hosts":       "whoami.example.com",
+		"goma.routes.whoami.path":        "/api",
+		"goma.routes.whoami.entrypoints": "websecure",
+		"goma.routes.whoami.middlewares": "auth",
+		"goma.routes.whoami.port":        "8080",
+	}
+	for key, value := range want {
+		if labels[key] != value {
+			t.Errorf("%s = %q, want %q", key, labels[key], value)
+		}
+	}
+	for key := range labels {
+		if strings.HasPrefix(key, "goma.routes.health.") {
+			t.Errorf("router with an exact Path translated: %s", key)
+		}
+	}
+	found := false
+	for _, d := range p.diagnostics {
+		if d.Severity == SeverityError && d.Label == "traefik.http.routers.health.rule" && strings.Contains(d.Message, "exact Path is not supported") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("no error for the exact Path router: %v", p.diagnostics)
+	}
+}
+
+func TestTraefikLabelsIgnoredWithoutCompat(t *testing.T) {
+	p := NewProvider(config.Default())
+	if _, ok := p.discoveryLabels("whoami", map[string]string{
+		"traefik.enable":                   "true",
+		"traefik.http.routers.whoami.rule": "Host(`whoami.example.com`)",
+	}); ok {
+		t.Error("Traefik labels translated without compatibility mode")
+	}
+}
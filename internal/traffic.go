@@ -0,0 +1,307 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// controllerLabel marks a container or service holding the traffic settings
+// of routes, so they can change without recreating application containers.
+const controllerLabel = "goma.controller"
+
+// goma.traffic.{routeName}.{setting}, settings are the keys of trafficFields
+var trafficPattern = func() *regexp.Regexp {
+	keys := make([]string, 0, len(trafficFields))
+	for _, field := range trafficFields {
+		keys = append(keys, regexp.QuoteMeta(field.key))
+	}
+	return regexp.MustCompile(`^goma\.traffic\.(.+)\.(` + strings.Join(keys, "|") + `)$`)
+}()
+
+// trafficSettings are the active color and canary weight of a route.
+type trafficSettings struct {
+	active string
+	// canaryWeight is -1 when not set
+	canaryWeight int
+}
+
+// setTraffic merges the traffic settings of the configuration with the ones
+// of controller containers or services, which take precedence.
+func (p *Provider) setTraffic(controllers []labeledSource) {
+	p.traffic = make(map[string]trafficSettings)
+	for route, traffic := range p.config.Traffic {
+		settings := trafficSettings{active: traffic.Active, canaryWeight: -1}
+		if traffic.CanaryWeight != nil {
+			settings.canaryWeight = *traffic.CanaryWeight
+		}
+		p.traffic[route] = settings
+	}
+
+	sort.Slice(controllers, func(i, j int) bool {
+		return controllers[i].name < controllers[j].name
+	})
+	for _, controller := range controllers {
+		for _, key := range sortedKeys(controller.labels) {
+			if !strings.HasPrefix(key, "goma.traffic.") {
+				continue
+			}
+			matches := trafficPattern.FindStringSubmatch(key)
+			if matches == nil {
+				p.warnf(controller.name, key, "unknown controller label, it will be ignored")
+				continue
+			}
+			value := controller.labels[key]
+			settings, ok := p.traffic[matches[1]]
+			if !ok {
+				settings = trafficSettings{canaryWeight: -1}
+			}
+			switch matches[2] {
+			case "active":
+				if value != "blue" && value != "green" {
+					p.errorf(controller.name, key, "invalid color %q, expected blue or green", value)
+					continue
+				}
+				settings.active = value
+			case "canary_weight":
+				weight, err := strconv.Atoi(value)
+				if err != nil || weight < 0 || weight > 100 {
+					p.errorf(controller.name, key, "invalid weight %q, expected an integer from 0 to 100", value)
+					continue
+				}
+				settings.canaryWeight = weight
+			}
+			p.traffic[matches[1]] = settings
+		}
+	}
+}
+
+// routeKey identifies a route by project and name, routes of different
+// projects are never merged.
+func routeKey(project, name string) string {
+	return project + "\x00" + name
+}
+
+// applyTraffic merges the blue/green variants and the canaries of routes
+// into routes with weighted backends.
+func (p *Provider) applyTraffic(routes []Route) []Route {
+	primaries := make([]Route, 0, len(routes))
+	canaries := make([]Route, 0)
+	for _, route := range routes {
+		if route.CanaryOf != "" {
+			canaries = append(canaries, route)
+		} else {
+			primaries = append(primaries, route)
+		}
+	}
+
+	primaries, endpoints := p.selectColors(primaries)
+
+	index := make(map[string]int, len(primaries))
+	for i, route := range primaries {
+		index[routeKey(route.Project, route.Name)] = i
+	}
+
+	// Canaries of each primary route, by index
+	canaryBackends := make(map[int][]Backend)
+	for _, canary := range canaries {
+		// canary.of names a route of the same project, prefixed as route
+		// names are, traffic settings use the same name
+		name := p.routeName(canary.CanaryOf, canary.Project)
+		i, ok := index[routeKey(canary.Project, name)]
+		if !ok {
+			p.errorf(canary.Source, "", "canary %q: route %q not found in the same project", canary.Name, name)
+			continue
+		}
+		weight := canary.CanaryWeight
+		if settings, ok := p.traffic[name]; ok && settings.canaryWeight >= 0 {
+			weight = settings.canaryWeight
+		}
+		if weight < 0 {
+			p.warnf(canary.Source, "", "canary %q has no weight, it receives no traffic", canary.Name)
+			weight = 0
+		}
+		if primary := primaries[i]; canary.H2C != primary.H2C || canary.EnableWebSocket != primary.EnableWebSocket {
+			p.warnf(canary.Source, "", "canary %q does not use the backend protocol of route %q, the protocol of the route applies", canary.Name, primary.Name)
+		}
+		canaryBackends[i] = append(canaryBackends[i], Backend{Endpoint: canary.Target, Weight: weight})
+	}
+
+	for i := range primaries {
+		route := &primaries[i]
+		backends := canaryBackends[i]
+		canaryWeight := 0
+		for _, backend := range backends {
+			canaryWeight += backend.Weight
+		}
+		if canaryWeight > 100 {
+			p.errorf(route.Source, "", "canaries of route %q have a total weight of %d, more than 100, they are ignored", route.Name, canaryWeight)
+			backends, canaryWeight = nil, 0
+		}
+
+		targets := endpoints[i]
+		if len(targets) == 1 && len(backends) == 0 {
+			continue
+		}
+
+		// The primary targets share the traffic left by the canaries
+		weights := make([]Backend, 0, len(targets)+len(backends))
+		share := 100 - canaryWeight
+		for j, target := range targets {
+			weight := share / len(targets)
+			if j == 0 {
+				weight += share % len(targets)
+			}
+			weights = append(weights, Backend{Endpoint: target, Weight: weight})
+		}
+		weights = append(weights, backends...)
+		route.setBackends(weights)
+	}
+	return primaries
+}
+
+// setBackends sets the weighted backends of a route, leaving out the ones
+// receiving no traffic. A single backend is written as the route target.
+func (r *Route) setBackends(backends []Backend) {
+	r.Backends = nil
+	for _, backend := range backends {
+		if backend.Weight > 0 {
+			r.Backends = append(r.Backends, backend)
+		}
+	}
+	if len(r.Backends) == 1 {
+		r.Target = r.Backends[0].Endpoint
+		r.Backends = nil
+		return
+	}
+	r.Target = ""
+}
+
+// selectColors keeps the routes of the active color of blue/green routes,
+// merging the routes of that color into one. It returns the routes and the
+// targets of each route.
+func (p *Provider) selectColors(routes []Route) ([]Route, [][]string) {
+	groups := make(map[string][]int)
+	for i, route := range routes {
+		if route.Color != "" {
+			key := routeKey(route.Project, route.Name)
+			groups[key] = append(groups[key], i)
+		}
+	}
+
+	selected := make([]Route, 0, len(routes))
+	endpoints := make([][]string, 0, len(routes))
+	for i, route := range routes {
+		if route.Color == "" {
+			selected = append(selected, route)
+			endpoints = append(endpoints, []string{route.Target})
+			continue
+		}
+		group := groups[routeKey(route.Project, route.Name)]
+		if group[0] != i {
+			// Merged into the first route of the group
+			continue
+		}
+
+		active := p.activeColor(routes, group)
+		var base *Route
+		targets := make([]string, 0, len(group))
+		for _, j := range group {
+			if routes[j].Color != active {
+				p.infof(routes[j].Source, "", "route %q is %s, the active color is %s", routes[j].Name, routes[j].Color, active)
+				continue
+			}
+			if base == nil {
+				base = &routes[j]
+			}
+			targets = append(targets, routes[j].Target)
+		}
+		selected = append(selected, *base)
+		endpoints = append(endpoints, targets)
+	}
+	return selected, endpoints
+}
+
+// activeColor returns the active color of a group of blue/green routes: the
+// one selected by a controller or the configuration if any route has it,
+// else the color of the oldest route.
+func (p *Provider) activeColor(routes []Route, group []int) string {
+	oldest := routes[group[0]]
+	for _, i := range group[1:] {
+		if routes[i].Created.Before(oldest.Created) {
+			oldest = routes[i]
+		}
+	}
+
+	settings, ok := p.traffic[oldest.Name]
+	if ok && settings.active != "" {
+		for _, i := range group {
+			if routes[i].Color == settings.active {
+				return settings.active
+			}
+		}
+		p.warnf(oldest.Source, "", "route %q has no %s backend, keeping %s", oldest.Name, settings.active, oldest.Color)
+		return oldest.Color
+	}
+
+	for _, i := range group {
+		if routes[i].Color != oldest.Color {
+			p.warnf(oldest.Source, "", "route %q has blue and green backends but no active color, keeping %s", oldest.Name, oldest.Color)
+			break
+		}
+	}
+	return oldest.Color
+}
+
+// checkLoadBalancing reports load-balancing settings that have no effect on
+// the backends of a route.
+func (p *Provider) checkLoadBalancing(routes []Route) {
+	for _, route := range routes {
+		lb := route.LoadBalancing
+		if lb == (LoadBalancing{}) {
+			continue
+		}
+		if len(route.Backends) == 0 {
+			p.warnf(route.Source, "", "route %q has a single backend, its load-balancing settings have no effect", route.Name)
+			continue
+		}
+		if lb.Algorithm != "" && lb.Algorithm != LBWeighted && weighted(route.Backends) {
+			p.warnf(route.Source, "", "route %q has weighted backends, their weights are ignored by the %s algorithm", route.Name, lb.Algorithm)
+		}
+	}
+}
+
+// weighted reports whether the backends do not all have the same weight.
+func weighted(backends []Backend) bool {
+	for _, backend := range backends[1:] {
+		if backend.Weight != backends[0].Weight {
+			return true
+		}
+	}
+	return false
+}
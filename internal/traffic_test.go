@@ -0,0 +1,227 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jkaninda/goma-docker-provider/internal/config"
+)
+
+func TestApplyTrafficCanaries(t *testing.T) {
+	weight := func(w int) *int { return &w }
+	tests := []struct {
+		name           string
+		groupByProject bool
+		traffic        map[string]config.Traffic
+		want           []Backend
+	}{
+		{
+			name: "canary weight from labels",
+			want: []Backend{{Endpoint: "http://api:8080", Weight: 90}, {Endpoint: "http://api-canary:8080", Weight: 10}},
+		},
+		{
+			name:    "canary weight from the traffic settings",
+			traffic: map[string]config.Traffic{"api": {CanaryWeight: weight(25)}},
+			want:    []Backend{{Endpoint: "http://api:8080", Weight: 75}, {Endpoint: "http://api-canary:8080", Weight: 25}},
+		},
+		{
+			name:           "grouped by project",
+			groupByProject: true,
+			want:           []Backend{{Endpoint: "http://api:8080", Weight: 90}, {Endpoint: "http://api-canary:8080", Weight: 10}},
+		},
+		{
+			name:           "grouped by project, traffic settings of the prefixed name",
+			groupByProject: true,
+			traffic:        map[string]config.Traffic{"shop-api": {CanaryWeight: weight(40)}},
+			want:           []Backend{{Endpoint: "http://api:8080", Weight: 60}, {Endpoint: "http://api-canary:8080", Weight: 40}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.GroupByProject = tt.groupByProject
+			cfg.Traffic = tt.traffic
+			p := NewProvider(cfg)
+			p.setTraffic(nil)
+			routes := []Route{
+				{Name: p.routeName("api", "shop"), Project: "shop", Target: "http://api:8080", Source: "api"},
+				{Name: p.routeName("api-canary", "shop"), Project: "shop", Target: "http://api-canary:8080", Source: "api-canary", CanaryOf: "api", CanaryWeight: 10},
+			}
+			got := p.applyTraffic(routes)
+			if len(p.diagnostics) > 0 {
+				t.Fatalf("unexpected diagnostics: %v", p.diagnostics)
+			}
+			if len(got) != 1 {
+				t.Fatalf("got %d routes, want 1", len(got))
+			}
+			if !equalBackends(got[0].Backends, tt.want) {
+				t.Errorf("backends = %v, want %v", got[0].Backends, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyTrafficCanaryOfOtherProject(t *testing.T) {
+	cfg := config.Default()
+	cfg.GroupByProject = true
+	p := NewProvider(cfg)
+	p.setTraffic(nil)
+	routes := []Route{
+		{Name: "shop-api", Project: "shop", Target: "http://api:8080"},
+		{Name: "blog-api-canary", Project: "blog", Target: "http://api-canary:8080", CanaryOf: "api", CanaryWeight: 10},
+	}
+	got := p.applyTraffic(routes)
+	if len(got) != 1 || got[0].Target != "http://api:8080" || len(got[0].Backends) != 0 {
+		t.Errorf("routes = %v, want the api route unchanged", got)
+	}
+	if !hasErrors(p.diagnostics) {
+		t.Error("expected an error for the canary of another project")
+	}
+}
+
+func TestApplyTrafficColors(t *testing.T) {
+	now := time.Now()
+	routes := []Route{
+		{Name: "web", Color: "blue", Target: "http://web-blue:80", Created: now.Add(-time.Hour)},
+		{Name: "web", Color: "green", Target: "http://web-green-1:80", Created: now},
+		{Name: "web", Color: "green", Target: "http://web-green-2:80", Created: now},
+	}
+	tests := []struct {
+		name    string
+		traffic map[string]config.Traffic
+		want    Route
+	}{
+		{"oldest color", nil, Route{Target: "http://web-blue:80"}},
+		{"active color", map[string]config.Traffic{"web": {Active: "green"}}, Route{Backends: []Backend{
+			{Endpoint: "http://web-green-1:80", Weight: 50},
+			{Endpoint: "http://web-green-2:80", Weight: 50},
+		}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Traffic = tt.traffic
+			p := NewProvider(cfg)
+			p.setTraffic(nil)
+			got := p.applyTraffic(append([]Route(nil), routes...))
+			if len(got) != 1 {
+				t.Fatalf("got %d routes, want 1", len(got))
+			}
+			if got[0].Target != tt.want.Target || !equalBackends(got[0].Backends, tt.want.Backends) {
+				t.Errorf("target = %q, backends = %v, want %q, %v", got[0].Target, got[0].Backends, tt.want.Target, tt.want.Backends)
+			}
+		})
+	}
+}
+
+func equalBackends(a, b []Backend) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestParseLoadBalancing(t *testing.T) {
+	p := NewProvider(config.Default())
+	parsed := parseTestContainer(p, "web", map[string]string{
+		"goma.enable":             "true",
+		"goma.lb.algorithm":       LBLeastConnections,
+		"goma.sticky.cookie_name": "goma_backend",
+		"goma.sticky.ttl":         "1h",
+	})
+	if len(parsed.Routes) != 1 || len(p.diagnostics) != 0 {
+		t.Fatalf("routes = %v, diagnostics = %v", parsed.Routes, p.diagnostics)
+	}
+	want := LoadBalancing{Algorithm: LBLeastConnections, StickySession: StickySession{CookieName: "goma_backend", TTL: "1h"}}
+	if got := parsed.Routes[0].LoadBalancing; got != want {
+		t.Errorf("load balancing = %+v, want %+v", got, want)
+	}
+
+	data, err := MarshalConfiguration(GomaConfig{Routes: parsed.Routes}, FormatYAML, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"loadBalancing:", "algorithm: least-connections", "stickySession:", "cookieName: goma_backend", "ttl: 1h"} {
+		if !strings.Contains(string(data), line) {
+			t.Errorf("output does not contain %q:\n%s", line, data)
+		}
+	}
+}
+
+func TestParseLoadBalancingErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels map[string]string
+		label  string
+		want   Severity
+	}{
+		{"invalid cookie name", map[string]string{"goma.sticky.cookie_name": "a cookie"}, "goma.sticky.cookie_name", SeverityError},
+		{"invalid ttl", map[string]string{"goma.sticky.cookie_name": "c", "goma.sticky.ttl": "-1h"}, "goma.sticky.ttl", SeverityError},
+		{"ttl without cookie", map[string]string{"goma.sticky.ttl": "1h"}, "goma.sticky.ttl", SeverityWarning},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewProvider(config.Default())
+			tt.labels["goma.enable"] = "true"
+			parseTestContainer(p, "web", tt.labels)
+			if len(p.diagnostics) != 1 || p.diagnostics[0].Label != tt.label || p.diagnostics[0].Severity != tt.want {
+				t.Errorf("diagnostics = %v, want one %s on %s", p.diagnostics, tt.want, tt.label)
+			}
+		})
+	}
+}
+
+func TestCheckLoadBalancing(t *testing.T) {
+	backends := []Backend{{Endpoint: "http://a:80", Weight: 90}, {Endpoint: "http://b:80", Weight: 10}}
+	tests := []struct {
+		name  string
+		route Route
+		warn  bool
+	}{
+		{"single backend", Route{Target: "http://a:80", LoadBalancing: LoadBalancing{Algorithm: LBIPHash}}, true},
+		{"weights ignored", Route{Backends: backends, LoadBalancing: LoadBalancing{Algorithm: LBRoundRobin}}, true},
+		{"weighted algorithm", Route{Backends: backends, LoadBalancing: LoadBalancing{Algorithm: LBWeighted}}, false},
+		{"sticky sessions only", Route{Backends: backends, LoadBalancing: LoadBalancing{StickySession: StickySession{CookieName: "c"}}}, false},
+		{"no settings", Route{Target: "http://a:80"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewProvider(config.Default())
+			tt.route.Name = "web"
+			p.checkLoadBalancing([]Route{tt.route})
+			if got := hasWarnings(p.diagnostics); got != tt.warn {
+				t.Errorf("diagnostics = %v, want a warning: %v", p.diagnostics, tt.warn)
+			}
+		})
+	}
+}
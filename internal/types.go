@@ -24,9 +24,22 @@
 
 package internal
 
+import "time"
+
 type GomaConfig struct {
 	Routes []Route `json:"routes" yaml:"routes"`
+	// L4Routes forward TCP or UDP traffic, they are written in their own section.
+	L4Routes []L4Route `json:"l4Routes,omitempty" yaml:"l4Routes,omitempty"`
+	// Middlewares are generated from route labels, each is private to its route.
+	Middlewares []Middleware `json:"middlewares,omitempty" yaml:"middlewares,omitempty"`
+}
+
+// append adds the routes of other to the configuration.
+func (c *GomaConfig) append(other GomaConfig) {
+	c.Routes = append(c.Routes, other.Routes...)
+	c.L4Routes = append(c.L4Routes, other.L4Routes...)
 }
+
 type (
 	Route struct {
 		// Name provides a descriptive name for the route.
@@ -44,15 +57,153 @@ type (
 		Hosts []string `yaml:"hosts,omitempty" json:"hosts,omitempty"`
 		// Methods specifies the HTTP methods allowed for this route (e.g., GET, POST).
 		Methods []string `yaml:"methods,omitempty" json:"methods,omitempty"`
+		// EntryPoints restricts the route to the listed gateway entry points.
+		EntryPoints []string `yaml:"entryPoints,omitempty" json:"entryPoints,omitempty"`
 		// Target defines the primary backend URL for this route.
 		Target string `yaml:"target,omitempty" json:"target,omitempty"`
+		// Backends replaces Target when traffic is split between weighted backends.
+		Backends []Backend `yaml:"backends,omitempty" json:"backends,omitempty"`
+		// H2C proxies requests to the backends with HTTP/2 over cleartext, as gRPC needs.
+		H2C bool `yaml:"h2c,omitempty" json:"h2c,omitempty"`
+		// EnableWebSocket allows upgrading requests to WebSocket connections.
+		EnableWebSocket bool `yaml:"enableWebSocket,omitempty" json:"enableWebSocket,omitempty"`
+		// LoadBalancing controls how traffic is spread across the backends.
+		LoadBalancing LoadBalancing `yaml:"loadBalancing,omitempty" json:"loadBalancing,omitempty"`
 		// HealthCheck contains configuration for monitoring the health of backends.
 		HealthCheck    RouteHealthCheck `yaml:"healthCheck,omitempty" json:"healthCheck,omitempty"`
 		Security       Security         `yaml:"security,omitempty" json:"security,omitempty"`
 		DisableMetrics bool             `yaml:"disableMetrics,omitempty" json:"disableMetrics,omitempty"`
 		Middlewares    []string         `yaml:"middlewares,omitempty" json:"middlewares,omitempty"`
+		// Maintenance answers requests with an error page instead of proxying them.
+		Maintenance Maintenance `yaml:"maintenance,omitempty" json:"maintenance,omitempty"`
+		// Source is the container or service the route was discovered from, it is not written.
+		Source string `yaml:"-" json:"-"`
+		// Created is the creation time of the source, it is not written.
+		Created time.Time `yaml:"-" json:"-"`
+		// FieldSources records where each field value comes from: label,
+		// project defaults or global defaults, it is not written.
+		FieldSources map[string]string `yaml:"-" json:"-"`
+		// Project is the compose project or Swarm stack of the source, it is not written.
+		Project string `yaml:"-" json:"-"`
+		// Color is the blue/green color of the route, it is not written.
+		Color string `yaml:"-" json:"-"`
+		// CanaryOf is the name of the route this route is a canary of, its
+		// target becomes a backend of that route. It is not written.
+		CanaryOf string `yaml:"-" json:"-"`
+		// CanaryWeight is the percentage of traffic sent to the canary, -1
+		// when not set. It is not written.
+		CanaryWeight int `yaml:"-" json:"-"`
+		// GeneratedMiddlewares are the middlewares generated from the labels
+		// of the route, they are written to the middlewares section.
+		GeneratedMiddlewares []Middleware `yaml:"-" json:"-"`
 	}
 )
+
+// Middleware is a gateway middleware generated by the provider.
+type Middleware struct {
+	Name string `yaml:"name" json:"name"`
+	Type string `yaml:"type" json:"type"`
+	// Paths are the paths, relative to the route path, the middleware applies to.
+	Paths []string `yaml:"paths,omitempty" json:"paths,omitempty"`
+	Rule  any      `yaml:"rule" json:"rule"`
+}
+
+// Middleware types
+const (
+	MiddlewareBasicAuth   = "basicAuth"
+	MiddlewareForwardAuth = "forwardAuth"
+	MiddlewareJWTAuth     = "jwtAuth"
+	// MiddlewareAccessPolicy allows or denies requests by client IP address.
+	MiddlewareAccessPolicy = "accessPolicy"
+	// MiddlewareHeaders sets and removes request and response headers.
+	MiddlewareHeaders = "headers"
+)
+
+type BasicAuthRule struct {
+	Realm string          `yaml:"realm,omitempty" json:"realm,omitempty"`
+	Users []BasicAuthUser `yaml:"users" json:"users"`
+}
+type BasicAuthUser struct {
+	Username string `yaml:"username" json:"username"`
+	// Password is a bcrypt hash.
+	Password string `yaml:"password" json:"password"`
+}
+type ForwardAuthRule struct {
+	// AuthURL is the authentication service endpoint.
+	AuthURL string `yaml:"authUrl" json:"authUrl"`
+	// AuthResponseHeaders are copied from the authentication response to the request.
+	AuthResponseHeaders []string `yaml:"authResponseHeaders,omitempty" json:"authResponseHeaders,omitempty"`
+}
+type AccessPolicyRule struct {
+	// Action is ALLOW, only the source ranges are allowed, or DENY.
+	Action       string   `yaml:"action" json:"action"`
+	SourceRanges []string `yaml:"sourceRanges" json:"sourceRanges"`
+}
+type HeadersRule struct {
+	Request  HeaderRule `yaml:"request,omitempty" json:"request,omitempty"`
+	Response HeaderRule `yaml:"response,omitempty" json:"response,omitempty"`
+}
+type HeaderRule struct {
+	// Set adds or replaces headers, keyed by canonical header name.
+	Set map[string]string `yaml:"set,omitempty" json:"set,omitempty"`
+	// Remove lists the canonical names of the headers removed.
+	Remove []string `yaml:"remove,omitempty" json:"remove,omitempty"`
+}
+
+func (r HeaderRule) empty() bool {
+	return len(r.Set) == 0 && len(r.Remove) == 0
+}
+
+type JWTAuthRule struct {
+	JwksURL  string `yaml:"jwksUrl" json:"jwksUrl"`
+	Issuer   string `yaml:"issuer,omitempty" json:"issuer,omitempty"`
+	Audience string `yaml:"audience,omitempty" json:"audience,omitempty"`
+}
+
+// L4Route forwards the TCP or UDP traffic of a gateway entry point to a backend.
+type L4Route struct {
+	Name string `yaml:"name" json:"name"`
+	// Protocol is tcp or udp.
+	Protocol string `yaml:"protocol" json:"protocol"`
+	// EntryPoint is the gateway entry point name, or listen address, receiving the traffic.
+	EntryPoint string `yaml:"entryPoint" json:"entryPoint"`
+	// Target is the backend address, host:port.
+	Target string `yaml:"target" json:"target"`
+	// TLSPassthrough forwards TLS connections to the backend without terminating them.
+	TLSPassthrough bool `yaml:"tlsPassthrough,omitempty" json:"tlsPassthrough,omitempty"`
+	// Hosts are the TLS server names (SNI) the route matches, with TLS passthrough only.
+	Hosts []string `yaml:"hosts,omitempty" json:"hosts,omitempty"`
+	// Source is the container or service the route was discovered from, it is not written.
+	Source string `yaml:"-" json:"-"`
+	// Created is the creation time of the source, it is not written.
+	Created time.Time `yaml:"-" json:"-"`
+}
+
+// Backend is a weighted backend of a route.
+type Backend struct {
+	Endpoint string `yaml:"endpoint" json:"endpoint"`
+	Weight   int    `yaml:"weight,omitempty" json:"weight,omitempty"`
+}
+
+// Load-balancing algorithms
+const (
+	LBRoundRobin       = "round-robin"
+	LBLeastConnections = "least-connections"
+	LBIPHash           = "ip-hash"
+	LBWeighted         = "weighted"
+)
+
+type LoadBalancing struct {
+	// Algorithm is round-robin, least-connections, ip-hash or weighted.
+	Algorithm     string        `yaml:"algorithm,omitempty" json:"algorithm,omitempty"`
+	StickySession StickySession `yaml:"stickySession,omitempty" json:"stickySession,omitempty"`
+}
+type StickySession struct {
+	// CookieName is the cookie pinning a client to a backend.
+	CookieName string `yaml:"cookieName,omitempty" json:"cookieName,omitempty"`
+	// TTL is the lifetime of the cookie.
+	TTL string `yaml:"ttl,omitempty" json:"ttl,omitempty"`
+}
 type RouteHealthCheck struct {
 	Path            string `yaml:"path,omitempty" json:"path,omitempty"`
 	Interval        string `yaml:"interval,omitempty" json:"interval,omitempty"`
@@ -0,0 +1,154 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/jkaninda/goma-docker-provider/internal/config"
+)
+
+// ValidateOptions controls how a compose file is validated.
+type ValidateOptions struct {
+	// Swarm reads deploy labels and builds service routes, as for a stack file.
+	Swarm bool
+	// Project overrides the compose project or stack name.
+	Project string
+	// PolicyFile applies a policy file to the routes.
+	PolicyFile string
+	// ConflictPolicy applies to conflicting routes, defaults to warn.
+	ConflictPolicy string
+	// GroupByProject prefixes route names with their project or stack.
+	GroupByProject bool
+	// EntryPoints are the gateway entry points routes may listen on.
+	EntryPoints []string
+	// GatewayConfig is the gateway goma.yml the entry points are read from.
+	GatewayConfig string
+	// TargetVersion is the gateway configuration version, defaults to the current one.
+	TargetVersion string
+	// TraefikCompat translates the Traefik labels of services without goma labels.
+	TraefikCompat bool
+}
+
+// ValidationResult holds the routes a compose file would produce and the
+// problems found in its labels.
+type ValidationResult struct {
+	Routes      []Route      `yaml:"routes" json:"routes"`
+	L4Routes    []L4Route    `yaml:"l4Routes,omitempty" json:"l4Routes,omitempty"`
+	Middlewares []Middleware `yaml:"middlewares,omitempty" json:"middlewares,omitempty"`
+	Diagnostics []Diagnostic `yaml:"diagnostics" json:"diagnostics"`
+}
+
+// HasErrors reports whether the validation found any error.
+func (r *ValidationResult) HasErrors() bool {
+	return hasErrors(r.Diagnostics)
+}
+
+// HasWarnings reports whether the validation found any error or warning.
+func (r *ValidationResult) HasWarnings() bool {
+	return hasWarnings(r.Diagnostics)
+}
+
+// ValidateComposeFile parses the goma labels of a docker-compose or Swarm stack
+// file offline, without a Docker daemon.
+func ValidateComposeFile(path string, opts ValidateOptions) (*ValidationResult, error) {
+	file, err := loadComposeFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Project != "" {
+		file.Name = opts.Project
+	}
+
+	cfg := config.Default()
+	cfg.EnableSwarm = opts.Swarm
+	cfg.GroupByProject = opts.GroupByProject
+	cfg.EntryPoints = opts.EntryPoints
+	cfg.GatewayConfig = opts.GatewayConfig
+	cfg.TraefikCompat = opts.TraefikCompat
+	if opts.TargetVersion != "" {
+		cfg.TargetVersion = opts.TargetVersion
+	}
+	if opts.ConflictPolicy != "" {
+		cfg.ConflictPolicy = opts.ConflictPolicy
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	p := NewProvider(cfg)
+	p.resolveReferences = false
+	p.setGlobalDefaults()
+	if p.entryPoints, p.entryPointAddresses, err = loadEntryPoints(cfg); err != nil {
+		return nil, err
+	}
+	if opts.PolicyFile != "" {
+		if p.policy, err = LoadPolicy(opts.PolicyFile); err != nil {
+			return nil, err
+		}
+	}
+	config := GomaConfig{Routes: make([]Route, 0)}
+	if opts.Swarm {
+		services := file.services()
+		p.addresses = serviceAddresses(services)
+		p.setProjectDefaults(serviceLabels(services, projectDefaultsLabel))
+		p.setTraffic(serviceLabels(services, controllerLabel))
+		for _, service := range services {
+			config.append(p.parseServiceLabels(service))
+		}
+	} else {
+		containers := file.containers()
+		p.addresses = containerAddresses(containers)
+		p.setProjectDefaults(containerLabels(containers, projectDefaultsLabel))
+		p.setTraffic(containerLabels(containers, controllerLabel))
+		for _, container := range containers {
+			config.append(p.parseContainerLabels(container))
+		}
+	}
+	config = p.finalizeConfiguration(config)
+
+	return &ValidationResult{Routes: config.Routes, L4Routes: config.L4Routes, Middlewares: config.Middlewares, Diagnostics: p.diagnostics}, nil
+}
+
+// containerLabels returns the containers labeled label=true.
+func containerLabels(containers []container.Summary, label string) []labeledSource {
+	sources := make([]labeledSource, 0)
+	for _, container := range containers {
+		if container.Labels[label] == "true" {
+			sources = append(sources, labeledSource{name: containerName(container), labels: container.Labels})
+		}
+	}
+	return sources
+}
+
+// serviceLabels returns the services labeled label=true.
+func serviceLabels(services []swarm.Service, label string) []labeledSource {
+	sources := make([]labeledSource, 0)
+	for _, service := range services {
+		if service.Spec.Labels[label] == "true" {
+			sources = append(sources, labeledSource{name: service.Spec.Name, labels: service.Spec.Labels})
+		}
+	}
+	return sources
+}
@@ -0,0 +1,289 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Gateway configuration versions the output can target
+const (
+	// TargetVersion1 is the legacy route schema: destination, plain backend
+	// URLs and flat security flags.
+	TargetVersion1 = "1"
+	// TargetVersion2 is the current route schema.
+	TargetVersion2 = "2"
+)
+
+// versionHeaderPattern matches the target version recorded in the header of a generated file.
+var versionHeaderPattern = regexp.MustCompile(`(?m)^# Target version: (\S+)$`)
+
+// targetVersion serializes the configuration for a gateway version.
+type targetVersion struct {
+	// document returns the value encoded in the output file.
+	document func(config GomaConfig) any
+	// decode parses the routes of a file generated for the version.
+	decode func(data []byte) ([]Route, error)
+	// unsupported returns the features of a route the version cannot express.
+	unsupported func(route Route) []string
+	// unprotected returns the features of a route the version cannot express
+	// and whose loss would widen its exposure, the route is rejected then.
+	unprotected func(route Route) []string
+	// l4Routes reports whether the version supports TCP and UDP routes.
+	l4Routes bool
+	// header reports whether files need the version header to be read back,
+	// files without one are read as the current version.
+	header bool
+}
+
+var targetVersions = map[string]targetVersion{
+	TargetVersion1: {
+		document:    v1Document,
+		decode:      v1Decode,
+		unsupported: v1Unsupported,
+		unprotected: v1Unprotected,
+		header:      true,
+	},
+	TargetVersion2: {
+		document: func(config GomaConfig) any { return config },
+		decode: func(data []byte) ([]Route, error) {
+			var config GomaConfig
+			err := yaml.Unmarshal(data, &config)
+			return config.Routes, err
+		},
+		unsupported: func(Route) []string { return nil },
+		unprotected: func(Route) []string { return nil },
+		l4Routes:    true,
+	},
+}
+
+// lookupTargetVersion returns the serializer of a version, the current one when version is empty.
+func lookupTargetVersion(version string) targetVersion {
+	if target, ok := targetVersions[version]; ok {
+		return target
+	}
+	return targetVersions[TargetVersion2]
+}
+
+// checkTargetVersion rejects the routes the target gateway version would
+// publish less protected than declared, and warns about the other features
+// it cannot express, they are left out of the output.
+func (p *Provider) checkTargetVersion(config GomaConfig) GomaConfig {
+	version := p.config.TargetVersion
+	target := lookupTargetVersion(version)
+	routes := make([]Route, 0, len(config.Routes))
+	rejected := make(map[string]bool)
+	for _, route := range config.Routes {
+		if features := target.unprotected(route); len(features) > 0 {
+			p.errorf(route.Source, "", "route %q rejected: %s is not supported by gateway version %s", route.Name, strings.Join(features, ", "), version)
+			for _, middleware := range route.GeneratedMiddlewares {
+				rejected[middleware.Name] = true
+			}
+			continue
+		}
+		for _, feature := range target.unsupported(route) {
+			p.warnf(route.Source, "", "route %q: %s is not supported by gateway version %s, it is not written", route.Name, feature, version)
+		}
+		routes = append(routes, route)
+	}
+	config.Routes = routes
+	if len(rejected) > 0 {
+		middlewares := make([]Middleware, 0, len(config.Middlewares))
+		for _, middleware := range config.Middlewares {
+			if !rejected[middleware.Name] {
+				middlewares = append(middlewares, middleware)
+			}
+		}
+		config.Middlewares = middlewares
+	}
+	if !target.l4Routes {
+		for _, route := range config.L4Routes {
+			p.warnf(route.Source, "", "%s route %q is not supported by gateway version %s, it is not written", route.Protocol, route.Name, version)
+		}
+	}
+	return config
+}
+
+// v1Config is the configuration document of gateway version 1.
+type v1Config struct {
+	Routes      []v1Route    `yaml:"routes" json:"routes"`
+	Middlewares []Middleware `yaml:"middlewares,omitempty" json:"middlewares,omitempty"`
+}
+
+// v1Route is a route of gateway version 1.
+type v1Route struct {
+	Name                  string           `yaml:"name" json:"name"`
+	Path                  string           `yaml:"path" json:"path"`
+	Rewrite               string           `yaml:"rewrite,omitempty" json:"rewrite,omitempty"`
+	Priority              int              `yaml:"priority,omitempty" json:"priority,omitempty"`
+	Enabled               bool             `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	Hosts                 []string         `yaml:"hosts,omitempty" json:"hosts,omitempty"`
+	Methods               []string         `yaml:"methods,omitempty" json:"methods,omitempty"`
+	Destination           string           `yaml:"destination,omitempty" json:"destination,omitempty"`
+	Backends              []string         `yaml:"backends,omitempty" json:"backends,omitempty"`
+	HealthCheck           RouteHealthCheck `yaml:"healthCheck,omitempty" json:"healthCheck,omitempty"`
+	DisableHostForwarding bool             `yaml:"disableHostForwarding,omitempty" json:"disableHostForwarding,omitempty"`
+	BlockCommonExploits   bool             `yaml:"blockCommonExploits,omitempty" json:"blockCommonExploits,omitempty"`
+	InsecureSkipVerify    bool             `yaml:"insecureSkipVerify,omitempty" json:"insecureSkipVerify,omitempty"`
+	DisableMetrics        bool             `yaml:"disableMetrics,omitempty" json:"disableMetrics,omitempty"`
+	Middlewares           []string         `yaml:"middlewares,omitempty" json:"middlewares,omitempty"`
+}
+
+// v1MiddlewareTypes are the middleware types gateway version 1 knows.
+var v1MiddlewareTypes = []string{MiddlewareBasicAuth, MiddlewareForwardAuth, MiddlewareJWTAuth, MiddlewareAccessPolicy}
+
+func v1Document(config GomaConfig) any {
+	doc := v1Config{Routes: make([]v1Route, 0, len(config.Routes))}
+	skipped := make(map[string]bool)
+	for _, middleware := range config.Middlewares {
+		if slices.Contains(v1MiddlewareTypes, middleware.Type) {
+			doc.Middlewares = append(doc.Middlewares, middleware)
+		} else {
+			skipped[middleware.Name] = true
+		}
+	}
+	for _, route := range config.Routes {
+		v1 := v1Route{
+			Name:                  route.Name,
+			Path:                  route.Path,
+			Rewrite:               route.Rewrite,
+			Priority:              route.Priority,
+			Enabled:               route.Enabled,
+			Hosts:                 route.Hosts,
+			Methods:               route.Methods,
+			Destination:           route.Target,
+			HealthCheck:           route.HealthCheck,
+			DisableHostForwarding: !route.Security.ForwardHostHeaders,
+			BlockCommonExploits:   route.Security.EnableExploitProtection,
+			InsecureSkipVerify:    route.Security.TLS.InsecureSkipVerify,
+			DisableMetrics:        route.DisableMetrics,
+		}
+		for _, backend := range route.Backends {
+			v1.Backends = append(v1.Backends, backend.Endpoint)
+		}
+		for _, middleware := range route.Middlewares {
+			if !skipped[middleware] {
+				v1.Middlewares = append(v1.Middlewares, middleware)
+			}
+		}
+		doc.Routes = append(doc.Routes, v1)
+	}
+	return doc
+}
+
+func v1Decode(data []byte) ([]Route, error) {
+	var doc v1Config
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	routes := make([]Route, 0, len(doc.Routes))
+	for _, v1 := range doc.Routes {
+		route := Route{
+			Name:        v1.Name,
+			Path:        v1.Path,
+			Rewrite:     v1.Rewrite,
+			Priority:    v1.Priority,
+			Enabled:     v1.Enabled,
+			Hosts:       v1.Hosts,
+			Methods:     v1.Methods,
+			Target:      v1.Destination,
+			HealthCheck: v1.HealthCheck,
+			Security: Security{
+				ForwardHostHeaders:      !v1.DisableHostForwarding,
+				EnableExploitProtection: v1.BlockCommonExploits,
+				TLS:                     SecurityTLS{InsecureSkipVerify: v1.InsecureSkipVerify},
+			},
+			DisableMetrics: v1.DisableMetrics,
+			Middlewares:    v1.Middlewares,
+		}
+		for _, endpoint := range v1.Backends {
+			route.Backends = append(route.Backends, Backend{Endpoint: endpoint})
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+func v1Unsupported(route Route) []string {
+	var features []string
+	for _, backend := range route.Backends {
+		if backend.Weight > 0 {
+			features = append(features, "backend weights")
+			break
+		}
+	}
+	if route.LoadBalancing != (LoadBalancing{}) {
+		features = append(features, "load balancing")
+	}
+	if route.H2C {
+		features = append(features, "h2c")
+	}
+	if route.EnableWebSocket {
+		features = append(features, "WebSocket")
+	}
+	return features
+}
+
+// v1Unprotected returns the features version 1 would drop from a route
+// restricting its exposure: its entry points, maintenance mode and generated
+// middlewares, such as the headers middleware.
+func v1Unprotected(route Route) []string {
+	var features []string
+	if len(route.EntryPoints) > 0 {
+		features = append(features, "entry points")
+	}
+	if route.Maintenance.Enabled {
+		features = append(features, "maintenance")
+	}
+	for _, middleware := range route.GeneratedMiddlewares {
+		if !slices.Contains(v1MiddlewareTypes, middleware.Type) {
+			features = append(features, fmt.Sprintf("%s middleware", middleware.Type))
+		}
+	}
+	return features
+}
+
+// fileVersion returns the target version recorded in a generated file,
+// files without one are from the current version.
+func fileVersion(data []byte) string {
+	if match := versionHeaderPattern.FindSubmatch(data); match != nil {
+		return string(match[1])
+	}
+	return TargetVersion2
+}
+
+// versionHeader is written at the top of the generated YAML files.
+func versionHeader(version string) string {
+	return strings.Join([]string{
+		"# Generated by Goma Gateway Docker provider",
+		"# DO NOT EDIT MANUALLY",
+		"# Target version: " + version,
+	}, "\n") + "\n\n"
+}
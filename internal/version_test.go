@@ -0,0 +1,256 @@
+/*
+ *  MIT License
+ *
+ * Copyright (c) 2026 Jonas Kaninda
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package internal
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jkaninda/goma-docker-provider/internal/config"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// goldenConfig uses the features of every target version.
+func goldenConfig() GomaConfig {
+	return GomaConfig{
+		Routes: []Route{
+			{
+				Name:        "api",
+				Path:        "/api",
+				Rewrite:     "/",
+				Priority:    10,
+				Enabled:     true,
+				Hosts:       []string{"api.example.com"},
+				Methods:     []string{"GET", "POST"},
+				EntryPoints: []string{"websecure"},
+				Backends: []Backend{
+					{Endpoint: "http://api:8080", Weight: 90},
+					{Endpoint: "http://api-canary:8080", Weight: 10},
+				},
+				LoadBalancing: LoadBalancing{Algorithm: LBWeighted},
+				HealthCheck:   RouteHealthCheck{Path: "/healthz", Interval: "10s", Timeout: "2s", HealthyStatuses: []int{200}},
+				Security: Security{
+					ForwardHostHeaders:      true,
+					EnableExploitProtection: true,
+				},
+				Middlewares: []string{"docker-api-access-allow", "docker-api-headers", "rate-limit"},
+				GeneratedMiddlewares: []Middleware{
+					{Name: "docker-api-access-allow", Type: MiddlewareAccessPolicy, Paths: []string{"/.*"}, Rule: AccessPolicyRule{Action: "ALLOW", SourceRanges: []string{"10.0.0.0/8"}}},
+					{Name: "docker-api-headers", Type: MiddlewareHeaders, Paths: []string{"/.*"}, Rule: HeadersRule{Response: HeaderRule{Set: map[string]string{"X-Frame-Options": "DENY"}}}},
+				},
+			},
+			{
+				Name:    "web",
+				Path:    "/",
+				Enabled: true,
+				Target:  "http://web:80",
+				Security: Security{
+					TLS: SecurityTLS{InsecureSkipVerify: true},
+				},
+				DisableMetrics: true,
+			},
+		},
+		L4Routes: []L4Route{
+			{Name: "postgres", Protocol: ProtocolTCP, EntryPoint: "postgres", Target: "db:5432"},
+		},
+		Middlewares: []Middleware{
+			{Name: "docker-api-access-allow", Type: MiddlewareAccessPolicy, Paths: []string{"/.*"}, Rule: AccessPolicyRule{Action: "ALLOW", SourceRanges: []string{"10.0.0.0/8"}}},
+			{Name: "docker-api-headers", Type: MiddlewareHeaders, Paths: []string{"/.*"}, Rule: HeadersRule{Response: HeaderRule{Set: map[string]string{"X-Frame-Options": "DENY"}}}},
+		},
+	}
+}
+
+func TestMarshalConfigurationGolden(t *testing.T) {
+	tests := []struct {
+		version, format, golden string
+	}{
+		{TargetVersion1, FormatYAML, "config_v1.yaml.golden"},
+		{TargetVersion2, FormatYAML, "config_v2.yaml.golden"},
+		{TargetVersion2, FormatJSON, "config_v2.json.golden"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			got, err := MarshalConfiguration(goldenConfig(), tt.format, tt.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, tt.golden, got)
+		})
+	}
+}
+
+// checkGolden compares output with a golden file of testdata, updating it with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	golden := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s, run go test -update to review the change:\n%s", golden, UnifiedDiff(golden, "output", want, got))
+	}
+}
+
+func TestTargetVersionRejectsUnprotectedRoutes(t *testing.T) {
+	headers := Middleware{Name: "docker-admin-headers", Type: MiddlewareHeaders, Paths: []string{"/.*"}, Rule: HeadersRule{Request: HeaderRule{Remove: []string{"Cookie"}}}}
+	tests := []struct {
+		feature string
+		route   Route
+		golden  string
+	}{
+		{
+			feature: "entry points",
+			route:   Route{Name: "admin", Path: "/admin", Enabled: true, Target: "http://admin:80", EntryPoints: []string{"internal"}},
+			golden:  "config_v1_entrypoints.yaml.golden",
+		},
+		{
+			feature: "maintenance",
+			route:   Route{Name: "admin", Path: "/admin", Enabled: true, Target: "http://admin:80", Maintenance: Maintenance{Enabled: true}},
+			golden:  "config_v1_maintenance.yaml.golden",
+		},
+		{
+			feature: "headers middleware",
+			route: Route{
+				Name: "admin", Path: "/admin", Enabled: true, Target: "http://admin:80",
+				Middlewares:          []string{headers.Name},
+				GeneratedMiddlewares: []Middleware{headers},
+			},
+			golden: "config_v1_headers.yaml.golden",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.feature, func(t *testing.T) {
+			p := NewProvider(config.Default())
+			p.config.TargetVersion = TargetVersion1
+			tt.route.Source = "admin"
+			cfg := GomaConfig{
+				Routes: []Route{tt.route, {Name: "web", Path: "/", Enabled: true, Target: "http://web:80", Source: "web"}},
+			}
+			cfg.Middlewares = append(cfg.Middlewares, tt.route.GeneratedMiddlewares...)
+			cfg = p.checkTargetVersion(cfg)
+
+			if len(p.diagnostics) != 1 || p.diagnostics[0].Severity != SeverityError ||
+				!strings.Contains(p.diagnostics[0].Message, `route "admin" rejected: `+tt.feature) {
+				t.Errorf("diagnostics = %v, want the route rejected", p.diagnostics)
+			}
+			got, err := MarshalConfiguration(cfg, FormatYAML, TargetVersion1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, tt.golden, got)
+		})
+	}
+}
+
+func TestTargetVersionWarnsUnsupported(t *testing.T) {
+	p := NewProvider(config.Default())
+	p.config.TargetVersion = TargetVersion1
+	cfg := p.checkTargetVersion(GomaConfig{
+		Routes: []Route{{Name: "ws", Path: "/", Target: "http://ws:80", EnableWebSocket: true, Source: "ws"}},
+	})
+	if len(cfg.Routes) != 1 || hasErrors(p.diagnostics) || !hasWarnings(p.diagnostics) {
+		t.Errorf("routes = %v, diagnostics = %v, want the route written with a warning", cfg.Routes, p.diagnostics)
+	}
+}
+
+func TestMarshalConfigurationDefaultVersion(t *testing.T) {
+	got, err := MarshalConfiguration(goldenConfig(), FormatYAML, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := MarshalConfiguration(goldenConfig(), FormatYAML, TargetVersion2)
+	if !bytes.Equal(got, want) {
+		t.Error("an empty version does not target the current version")
+	}
+}
+
+func TestMarshalConfigurationJSONNeedsHeader(t *testing.T) {
+	_, err := MarshalConfiguration(goldenConfig(), FormatJSON, TargetVersion1)
+	if err == nil || !strings.Contains(err.Error(), "JSON output is not supported for gateway version 1") {
+		t.Errorf("error = %v, want JSON output rejected for version 1", err)
+	}
+}
+
+func TestDecodeRoundTrip(t *testing.T) {
+	// Only the fields version 1 can express
+	routes := []Route{
+		{
+			Name:        "api",
+			Path:        "/api",
+			Rewrite:     "/",
+			Priority:    10,
+			Enabled:     true,
+			Hosts:       []string{"api.example.com"},
+			Methods:     []string{"GET"},
+			Backends:    []Backend{{Endpoint: "http://api-1:8080"}, {Endpoint: "http://api-2:8080"}},
+			HealthCheck: RouteHealthCheck{Path: "/healthz", HealthyStatuses: []int{200, 204}},
+			Security:    Security{ForwardHostHeaders: true, EnableExploitProtection: true},
+			Middlewares: []string{"rate-limit"},
+		},
+		{
+			Name:           "web",
+			Path:           "/",
+			Target:         "http://web:80",
+			Security:       Security{TLS: SecurityTLS{InsecureSkipVerify: true}},
+			DisableMetrics: true,
+		},
+	}
+	for _, version := range []string{TargetVersion1, TargetVersion2} {
+		t.Run("version "+version, func(t *testing.T) {
+			data, err := MarshalConfiguration(GomaConfig{Routes: routes}, FormatYAML, version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fileVersion(data); got != version {
+				t.Fatalf("fileVersion() = %q, want %q", got, version)
+			}
+			decoded, err := lookupTargetVersion(fileVersion(data)).decode(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(decoded, routes) {
+				t.Errorf("decoded routes differ:\ngot  %+v\nwant %+v", decoded, routes)
+			}
+		})
+	}
+}
+
+func TestFileVersionWithoutHeader(t *testing.T) {
+	if got := fileVersion([]byte("routes: []\n")); got != TargetVersion2 {
+		t.Errorf("fileVersion() = %q, want the current version", got)
+	}
+}